@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/lint"
+)
+
+const (
+	skipMtime = "mtime"
+	skipHash  = "hash"
+	skipNone  = "none"
+)
+
+type (
+	batchOptions struct {
+		renderOptions
+		extension string
+		skip      string
+		jobs      int
+		overwrite bool
+	}
+	batchTask struct {
+		source, destination string
+		convert             bool     // djot files are converted, other files are copied as is
+		name                string   // slash separated path relative to the input directory
+		includes            []string // names of the files included by the converted file
+	}
+	batchSummary struct {
+		converted, copied, skipped, included int
+		errors                               []error
+	}
+)
+
+// runBatch converts djot files of the input directory into the mirrored output tree, problems with separate files
+// are reported at the end and don't stop conversion of other files
+func runBatch(from, to string, options batchOptions) int {
+	if to == "" || to == "-" {
+		log.Printf("output directory is required when input %v is a directory", from)
+		return 1
+	}
+	if options.skip != skipMtime && options.skip != skipHash && options.skip != skipNone {
+		log.Printf("unsupported skip mode %v", options.skip)
+		return 1
+	}
+	if options.extension == "" {
+		options.extension = ".html"
+		if options.format == djot_parser.TermFormat {
+			options.extension = ".txt"
+		}
+	}
+	options.jobs = max(options.jobs, 1)
+	summary := convertTree(from, to, options)
+	for _, err := range summary.errors {
+		log.Print(err)
+	}
+	log.Printf(
+		"converted %d, copied %d, skipped %d, included %d, failed %d files",
+		summary.converted, summary.copied, summary.skipped, summary.included, len(summary.errors),
+	)
+	if len(summary.errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func convertTree(from, to string, options batchOptions) batchSummary {
+	var summary batchSummary
+	output, err := filepath.Abs(to)
+	if err != nil {
+		summary.errors = append(summary.errors, err)
+		return summary
+	}
+	tasks := make([]batchTask, 0)
+	err = filepath.WalkDir(from, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			summary.errors = append(summary.errors, err)
+			return nil
+		}
+		if entry.IsDir() {
+			if absolute, err := filepath.Abs(path); err == nil && absolute == output {
+				// output tree inside of the input directory must not be converted again
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relative, err := filepath.Rel(from, path)
+		if err != nil {
+			summary.errors = append(summary.errors, err)
+			return nil
+		}
+		task := batchTask{
+			source:      path,
+			destination: filepath.Join(to, relative),
+			convert:     filepath.Ext(path) == lint.Extension,
+			name:        filepath.ToSlash(relative),
+		}
+		if task.convert {
+			task.destination = strings.TrimSuffix(task.destination, lint.Extension) + options.extension
+		}
+		tasks = append(tasks, task)
+		return nil
+	})
+	if err != nil {
+		summary.errors = append(summary.errors, err)
+	}
+	// fragments included by other files are converted only as parts of them, not into standalone pages
+	fsys := os.DirFS(from)
+	included := make(map[string]bool)
+	for i, task := range tasks {
+		if task.convert {
+			// unreadable file is reported by process
+			tasks[i].includes, _ = djot_parser.IncludedFiles(fsys, task.name, 0)
+			for _, name := range tasks[i].includes {
+				included[name] = true
+			}
+		}
+	}
+
+	var (
+		mutex sync.Mutex
+		group sync.WaitGroup
+		queue = make(chan batchTask)
+	)
+	for i := 0; i < options.jobs; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for task := range queue {
+				skipped, err := options.process(from, task)
+				mutex.Lock()
+				switch {
+				case err != nil:
+					summary.errors = append(summary.errors, fmt.Errorf("%v: %w", task.source, err))
+				case skipped:
+					summary.skipped++
+				case task.convert:
+					summary.converted++
+				default:
+					summary.copied++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, task := range tasks {
+		if task.convert && included[task.name] {
+			summary.included++
+			continue
+		}
+		queue <- task
+	}
+	close(queue)
+	group.Wait()
+	sort.Slice(summary.errors, func(i, j int) bool { return summary.errors[i].Error() < summary.errors[j].Error() })
+	return summary
+}
+
+// process converts or copies single file and reports whether it was skipped as unchanged; converted file is unchanged
+// only if its included files are unchanged too
+func (o batchOptions) process(from string, task batchTask) (bool, error) {
+	fsys := os.DirFS(from)
+	source, err := os.Stat(task.source)
+	if err != nil {
+		return false, err
+	}
+	modified := source.ModTime()
+	for _, name := range task.includes {
+		if info, err := fs.Stat(fsys, name); err == nil && info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	destination, err := os.Stat(task.destination)
+	exists := err == nil
+	if exists && o.skip == skipMtime && !destination.ModTime().Before(modified) {
+		return true, nil
+	}
+	content, err := os.ReadFile(task.source)
+	if err != nil {
+		return false, err
+	}
+	if task.convert {
+		var diagnostics []djot_parser.Diagnostic
+		content, diagnostics = o.render(fsys, task.name, content)
+		logIncludeProblems(from, diagnostics)
+	}
+	if exists && o.skip == skipHash {
+		if hash, err := fileHash(task.destination); err == nil && hash == sha256.Sum256(content) {
+			return true, nil
+		}
+	}
+	// outdated output is replaced when skip mode decides about changes, otherwise it requires -overwrite
+	if exists && o.skip == skipNone && !o.overwrite {
+		return false, fmt.Errorf("output file %v already exists", task.destination)
+	}
+	if err := os.MkdirAll(filepath.Dir(task.destination), 0750); err != nil {
+		return false, err
+	}
+	mode := os.FileMode(0640)
+	if !task.convert {
+		// copied assets keep permissions of the source, e.g. executable scripts
+		mode = source.Mode().Perm()
+	}
+	return false, writeFileAtomic(task.destination, content, mode, exists)
+}
+
+func fileHash(path string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return hash, err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return hash, err
+	}
+	copy(hash[:], hasher.Sum(nil))
+	return hash, nil
+}
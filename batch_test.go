@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"md0.org/djot/internal/testx"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		testx.AssertNilError(t, "", os.MkdirAll(filepath.Dir(path), 0750))
+		testx.AssertNilError(t, "", os.WriteFile(path, []byte(content), 0640))
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	testx.AssertNilError(t, "", err)
+	return string(content)
+}
+
+func TestConvertTree(t *testing.T) {
+	input, output := t.TempDir(), filepath.Join(t.TempDir(), "site")
+	writeFiles(t, input, map[string]string{
+		"index.djot":       "_home_\n",
+		"docs/intro.djot":  "# Intro\n",
+		"docs/image.png":   "png",
+		"docs/deep/a.djot": "a\n",
+	})
+	options := batchOptions{renderOptions: renderOptions{format: "html"}, extension: ".html", skip: skipMtime, jobs: 2}
+
+	summary := convertTree(input, output, options)
+	testx.AssertEqual(t, "", 0, len(summary.errors))
+	testx.AssertEqual(t, "", []int{3, 1, 0}, []int{summary.converted, summary.copied, summary.skipped})
+	testx.AssertEqual(t, "", "<p><em>home</em></p>\n", readFile(t, filepath.Join(output, "index.html")))
+	testx.AssertEqual(t, "", "<p>a</p>\n", readFile(t, filepath.Join(output, "docs", "deep", "a.html")))
+	testx.AssertEqual(t, "", "png", readFile(t, filepath.Join(output, "docs", "image.png")))
+
+	t.Run("skip by mtime", func(t *testing.T) {
+		summary := convertTree(input, output, options)
+		testx.AssertEqual(t, "", []int{0, 0, 4}, []int{summary.converted, summary.copied, summary.skipped})
+
+		future := time.Now().Add(time.Hour)
+		testx.AssertNilError(t, "", os.Chtimes(filepath.Join(input, "index.djot"), future, future))
+		summary = convertTree(input, output, options)
+		testx.AssertEqual(t, "", 0, len(summary.errors))
+		testx.AssertEqual(t, "", []int{1, 0, 3}, []int{summary.converted, summary.copied, summary.skipped})
+	})
+	t.Run("skip by hash", func(t *testing.T) {
+		options := options
+		options.skip = skipHash
+		writeFiles(t, input, map[string]string{"docs/deep/a.djot": "b\n"})
+		summary := convertTree(input, output, options)
+		testx.AssertEqual(t, "", []int{1, 0, 3}, []int{summary.converted, summary.copied, summary.skipped})
+		testx.AssertEqual(t, "", "<p>b</p>\n", readFile(t, filepath.Join(output, "docs", "deep", "a.html")))
+	})
+	t.Run("asset permissions", func(t *testing.T) {
+		writeFiles(t, input, map[string]string{"run.sh": "#!/bin/sh\n"})
+		testx.AssertNilError(t, "", os.Chmod(filepath.Join(input, "run.sh"), 0750))
+		summary := convertTree(input, output, options)
+		testx.AssertEqual(t, "", 1, summary.copied)
+		info, err := os.Stat(filepath.Join(output, "run.sh"))
+		testx.AssertNilError(t, "", err)
+		testx.AssertEqual(t, "", os.FileMode(0750), info.Mode().Perm())
+	})
+	t.Run("includes", func(t *testing.T) {
+		input, output := t.TempDir(), t.TempDir()
+		writeFiles(t, input, map[string]string{
+			"docs/page.djot":    "{include=\"../parts/w.djot\"}\n:::\n:::\n",
+			"parts/w.djot":      "_warn_\n",
+			"parts/unused.djot": "u\n",
+		})
+		summary := convertTree(input, output, options)
+		testx.AssertEqual(t, "", 0, len(summary.errors))
+		testx.AssertEqual(t, "", []int{2, 0, 1}, []int{summary.converted, summary.skipped, summary.included})
+		testx.AssertEqual(t, "", "<p><em>warn</em></p>\n", readFile(t, filepath.Join(output, "docs", "page.html")))
+		testx.AssertEqual(t, "", []string{"unused.html"}, listDir(t, filepath.Join(output, "parts")))
+
+		// changed fragment makes the including page outdated
+		future := time.Now().Add(time.Hour)
+		writeFiles(t, input, map[string]string{"parts/w.djot": "_changed_\n"})
+		testx.AssertNilError(t, "", os.Chtimes(filepath.Join(input, "parts", "w.djot"), future, future))
+		summary = convertTree(input, output, options)
+		testx.AssertEqual(t, "", []int{1, 1, 1}, []int{summary.converted, summary.skipped, summary.included})
+		testx.AssertEqual(t, "", "<p><em>changed</em></p>\n", readFile(t, filepath.Join(output, "docs", "page.html")))
+	})
+	t.Run("errors don't stop conversion", func(t *testing.T) {
+		options := options
+		options.skip = skipNone
+		broken := t.TempDir()
+		writeFiles(t, broken, map[string]string{"a.djot": "a\n", "b.djot": "b\n", "out/b.html/file": ""})
+		summary := convertTree(broken, filepath.Join(broken, "out"), options)
+		testx.AssertEqual(t, "", 1, len(summary.errors))
+		testx.AssertEqual(t, "", 1, summary.converted)
+		testx.AssertEqual(t, "", "<p>a</p>\n", readFile(t, filepath.Join(broken, "out", "a.html")))
+	})
+}
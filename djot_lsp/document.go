@@ -0,0 +1,431 @@
+package djot_lsp
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/lint"
+	"md0.org/djot/tokenizer"
+)
+
+type usageKind int
+
+const (
+	referenceUsage usageKind = iota
+	footnoteUsage
+	urlUsage
+)
+
+type (
+	heading struct {
+		level     int
+		title, id string
+		rng       tokenizer.Range
+	}
+	definition struct {
+		rng    tokenizer.Range
+		target string // link destination for references and text preview for footnotes
+	}
+	usage struct {
+		kind  usageKind
+		label string // reference label, footnote label or link destination
+		rng   tokenizer.Range
+	}
+	// Document is a parsed snapshot of the text with index of headings, definitions and their usages
+	Document struct {
+		Text        []byte
+		lineStarts  []int
+		tokens      tokenizer.TokenList[djot_tokenizer.DjotToken]
+		headings    []heading
+		references  map[string]definition
+		footnotes   map[string]definition
+		usages      []usage
+		diagnostics []djot_parser.Diagnostic
+	}
+)
+
+func NewDocument(text []byte) *Document {
+	return NewDocumentWithOptions(text, djot_parser.ParseOptions{})
+}
+
+// NewDocumentWithOptions indexes the text with section ids and diagnostics made with the options, like the converter
+// and the linter do
+func NewDocumentWithOptions(text []byte, options djot_parser.ParseOptions) *Document {
+	d := &Document{
+		Text:       text,
+		lineStarts: []int{0},
+		tokens:     djot_tokenizer.BuildDjotTokens(text),
+		references: make(map[string]definition),
+		footnotes:  make(map[string]definition),
+	}
+	for i, c := range text {
+		if c == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+	context := djot_parser.BuildDjotContextWithOptions(text, d.tokens, options)
+	d.index(context)
+	d.diagnostics = lint.Check(text, lint.Config{Options: options})
+	return d
+}
+
+func (d *Document) index(context djot_parser.DjotContext) {
+	list := d.tokens
+	for i, token := range list {
+		if token.JumpToPair <= 0 {
+			continue
+		}
+		closeToken := list[i+token.JumpToPair]
+		rng := tokenizer.Range{Start: token.Start, End: closeToken.End}
+		switch token.Type {
+		case djot_tokenizer.HeadingBlock:
+			title := strings.TrimSpace(string(djot_parser.SelectText(d.Text, list[i+1:i+token.JumpToPair])))
+			d.headings = append(d.headings, heading{
+				level: token.PrefixLength(d.Text, '#'),
+				title: title,
+				id:    context.Options.SectionId(title),
+				rng:   tokenizer.Range{Start: token.Start, End: token.Start + len(bytes.TrimRight(d.Text[token.Start:closeToken.Start], "\r\n"))},
+			})
+		case djot_tokenizer.ReferenceDefBlock:
+			d.references[token.Attributes.Get(djot_tokenizer.ReferenceKey)] = definition{
+				rng:    rng,
+				target: string(context.References[token.Attributes.Get(djot_tokenizer.ReferenceKey)]),
+			}
+		case djot_tokenizer.FootnoteDefBlock:
+			d.footnotes[token.Attributes.Get(djot_tokenizer.ReferenceKey)] = definition{
+				rng:    rng,
+				target: strings.TrimSpace(string(d.Text[token.End:closeToken.Start])),
+			}
+		case djot_tokenizer.FootnoteReferenceInline:
+			d.usages = append(d.usages, usage{kind: footnoteUsage, label: string(d.Text[token.End:closeToken.Start]), rng: rng})
+		case djot_tokenizer.SpanInline, djot_tokenizer.ImageSpanInline:
+			next := i + token.JumpToPair + 1
+			if next >= len(list) || (list[next].Type != djot_tokenizer.LinkUrlInline && list[next].Type != djot_tokenizer.LinkReferenceInline) {
+				continue
+			}
+			destination := list[next+list[next].JumpToPair]
+			rng = tokenizer.Range{Start: token.Start, End: destination.End}
+			label := string(bytes.ReplaceAll(d.Text[list[next].End:destination.Start], []byte("\n"), nil))
+			if list[next].Type == djot_tokenizer.LinkUrlInline {
+				d.usages = append(d.usages, usage{kind: urlUsage, label: strings.TrimSpace(label), rng: rng})
+				continue
+			}
+			if label == "" {
+				label = string(djot_parser.SelectText(d.Text, list[i+1:i+token.JumpToPair]))
+			}
+			d.usages = append(d.usages, usage{kind: referenceUsage, label: label, rng: rng})
+		}
+	}
+}
+
+// Position converts byte offset to LSP position
+func (d *Document) Position(offset int) Position {
+	offset = min(max(offset, 0), len(d.Text))
+	line := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+	character := 0
+	for _, c := range string(d.Text[d.lineStarts[line]:offset]) {
+		character += utf16.RuneLen(c)
+	}
+	return Position{Line: line, Character: character}
+}
+
+// Offset converts LSP position to byte offset; positions outside the text are clamped
+func (d *Document) Offset(position Position) int {
+	if position.Line < 0 {
+		return 0
+	}
+	if position.Line >= len(d.lineStarts) {
+		return len(d.Text)
+	}
+	offset, character := d.lineStarts[position.Line], 0
+	for offset < len(d.Text) && d.Text[offset] != '\n' && character < position.Character {
+		c, size := utf8.DecodeRune(d.Text[offset:])
+		offset, character = offset+size, character+utf16.RuneLen(c)
+	}
+	return offset
+}
+
+func (d *Document) Range(rng tokenizer.Range) Range {
+	return Range{Start: d.Position(rng.Start), End: d.Position(rng.End)}
+}
+
+func (d *Document) Diagnostics() []Diagnostic {
+	diagnostics := make([]Diagnostic, 0, len(d.diagnostics))
+	for _, diagnostic := range d.diagnostics {
+		severity := warningLevel
+		if diagnostic.Severity == djot_parser.ErrorSeverity {
+			severity = errorLevel
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.Range(diagnostic.Range),
+			Severity: severity,
+			Code:     diagnostic.Rule,
+			Source:   "djot",
+			Message:  diagnostic.Message,
+		})
+	}
+	return diagnostics
+}
+
+// Symbols returns headings nested by their levels; symbol range spans the whole section
+func (d *Document) Symbols() []DocumentSymbol {
+	type frame struct {
+		level  int
+		symbol DocumentSymbol
+	}
+	root := frame{symbol: DocumentSymbol{Children: []DocumentSymbol{}}}
+	stack := []frame{root}
+	pop := func(end int) {
+		last := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		last.symbol.Range.End = d.Position(end)
+		parent := &stack[len(stack)-1].symbol
+		parent.Children = append(parent.Children, last.symbol)
+	}
+	for _, h := range d.headings {
+		for len(stack) > 1 && stack[len(stack)-1].level >= h.level {
+			pop(h.rng.Start)
+		}
+		rng := d.Range(h.rng)
+		stack = append(stack, frame{level: h.level, symbol: DocumentSymbol{
+			Name:           h.title,
+			Detail:         "#" + h.id,
+			Kind:           moduleSymbol,
+			Range:          rng,
+			SelectionRange: rng,
+		}})
+	}
+	for len(stack) > 1 {
+		pop(len(d.Text))
+	}
+	return stack[0].symbol.Children
+}
+
+// usageAt returns the innermost usage around the offset (image can be nested in the link)
+func (d *Document) usageAt(offset int) (usage, bool) {
+	found, ok := usage{}, false
+	for _, u := range d.usages {
+		if u.rng.Start <= offset && offset <= u.rng.End && (!ok || u.rng.Start >= found.rng.Start) {
+			found, ok = u, true
+		}
+	}
+	return found, ok
+}
+
+func (d *Document) headingById(id string) (heading, bool) {
+	for _, h := range d.headings {
+		if h.id == id {
+			return h, true
+		}
+	}
+	return heading{}, false
+}
+
+// headingByReference finds heading referenced like [Heading Text][] or by its id, the same as the parser does
+func (d *Document) headingByReference(label string) (heading, bool) {
+	for _, h := range d.headings {
+		if h.title == strings.TrimSpace(label) || h.id == label {
+			return h, true
+		}
+	}
+	return heading{}, false
+}
+
+// Definition finds reference definition, footnote or section which is used at the offset
+func (d *Document) Definition(offset int) (Range, bool) {
+	u, ok := d.usageAt(offset)
+	if !ok {
+		return Range{}, false
+	}
+	switch u.kind {
+	case footnoteUsage:
+		if footnote, ok := d.footnotes[u.label]; ok {
+			return d.Range(footnote.rng), true
+		}
+	case referenceUsage:
+		if reference, ok := d.references[u.label]; ok {
+			return d.Range(reference.rng), true
+		}
+		if h, ok := d.headingByReference(u.label); ok {
+			return d.Range(h.rng), true
+		}
+	case urlUsage:
+		if id, ok := strings.CutPrefix(u.label, "#"); ok {
+			if h, ok := d.headingById(id); ok {
+				return d.Range(h.rng), true
+			}
+		}
+	}
+	return Range{}, false
+}
+
+// Hover previews link destination, section title or footnote text
+func (d *Document) Hover(offset int) (Hover, bool) {
+	u, ok := d.usageAt(offset)
+	if !ok {
+		return Hover{}, false
+	}
+	target := u.label
+	switch u.kind {
+	case footnoteUsage:
+		footnote, ok := d.footnotes[u.label]
+		if !ok {
+			return Hover{}, false
+		}
+		target = footnote.target
+	case referenceUsage:
+		if reference, ok := d.references[u.label]; ok {
+			target = "`" + reference.target + "`"
+		} else if h, ok := d.headingByReference(u.label); ok {
+			target = fmt.Sprintf("Section **%v** (`#%v`)", h.title, h.id)
+		} else {
+			return Hover{}, false
+		}
+	case urlUsage:
+		if h, ok := d.headingById(strings.TrimPrefix(u.label, "#")); ok && strings.HasPrefix(u.label, "#") {
+			target = fmt.Sprintf("Section **%v** (`#%v`)", h.title, h.id)
+		} else {
+			target = "`" + u.label + "`"
+		}
+	}
+	rng := d.Range(u.rng)
+	return Hover{Contents: MarkupContent{Kind: markdownKind, Value: target}, Range: &rng}, true
+}
+
+var (
+	footnoteCompletion  = regexp.MustCompile(`\[\^[^\]\s]*$`)
+	sectionCompletion   = regexp.MustCompile(`\]\(#[^)\s]*$`)
+	referenceCompletion = regexp.MustCompile(`\]\[[^\]]*$`)
+)
+
+// Complete suggests footnote labels after "[^", section ids after "](#" and references after "]["
+func (d *Document) Complete(offset int) []CompletionItem {
+	line := d.Text[d.lineStarts[d.Position(offset).Line]:offset]
+	items := make([]CompletionItem, 0)
+	addSections := func() {
+		for _, h := range d.headings {
+			items = append(items, CompletionItem{Label: h.id, Kind: referenceKind, Detail: h.title})
+		}
+	}
+	switch {
+	case footnoteCompletion.Match(line):
+		for _, label := range sortedKeys(d.footnotes) {
+			items = append(items, CompletionItem{Label: label, Kind: textKind, Detail: d.footnotes[label].target})
+		}
+	case sectionCompletion.Match(line):
+		addSections()
+	case referenceCompletion.Match(line):
+		for _, label := range sortedKeys(d.references) {
+			items = append(items, CompletionItem{Label: label, Kind: referenceKind, Detail: d.references[label].target})
+		}
+		addSections()
+	}
+	return items
+}
+
+func sortedKeys(definitions map[string]definition) []string {
+	keys := make([]string, 0, len(definitions))
+	for key := range definitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Format normalizes whitespace outside of code blocks and verbatim: trailing spaces are removed, blank lines are collapsed and
+// document ends with exactly one newline; the whole text is replaced by a single edit
+func (d *Document) Format() []TextEdit {
+	verbatim := make([]tokenizer.Range, 0)
+	for i, token := range d.tokens {
+		if token.Type == djot_tokenizer.CodeBlock || token.Type == djot_tokenizer.VerbatimInline {
+			verbatim = append(verbatim, tokenizer.Range{Start: token.Start, End: d.tokens[i+token.JumpToPair].End})
+		}
+	}
+	inVerbatim := func(start, end int) bool {
+		for _, rng := range verbatim {
+			if rng.Start <= end && start < rng.End {
+				return true
+			}
+		}
+		return false
+	}
+	// line endings of the document (taken from its first line) are kept
+	newline := []byte("\n")
+	if end := bytes.IndexByte(d.Text, '\n'); end > 0 && d.Text[end-1] == '\r' {
+		newline = []byte("\r\n")
+	}
+	var formatted bytes.Buffer
+	blank := false
+	for i, start := range d.lineStarts {
+		end := len(d.Text)
+		if i+1 < len(d.lineStarts) {
+			end = d.lineStarts[i+1] - 1
+		}
+		if start > end {
+			break
+		}
+		line := bytes.TrimSuffix(d.Text[start:end], []byte("\r"))
+		if inVerbatim(start, end) {
+			formatted.Write(line)
+			formatted.Write(newline)
+			blank = false
+			continue
+		}
+		line = bytes.TrimRight(line, " \t")
+		if len(line) == 0 {
+			if !blank && formatted.Len() > 0 {
+				formatted.Write(newline)
+			}
+			blank = true
+			continue
+		}
+		formatted.Write(line)
+		formatted.Write(newline)
+		blank = false
+	}
+	text := bytes.TrimRight(formatted.Bytes(), "\r\n")
+	if len(text) > 0 {
+		text = append(text, newline...)
+	}
+	if bytes.Equal(text, d.Text) {
+		return []TextEdit{}
+	}
+	return []TextEdit{{Range: d.Range(tokenizer.Range{Start: 0, End: len(d.Text)}), NewText: string(text)}}
+}
+
+// SemanticTokens encodes classified ranges of the text relative to each other; ranges are split by lines because
+// clients aren't required to support multiline tokens
+func (d *Document) SemanticTokens() SemanticTokens {
+	data := make([]int, 0)
+	previous := Position{}
+	for _, token := range djot_tokenizer.SemanticTokens(d.Text, d.tokens) {
+		if token.Class == djot_tokenizer.TextClass {
+			continue
+		}
+		for start := token.Range.Start; start < token.Range.End; {
+			end := token.Range.End
+			if newline := bytes.IndexByte(d.Text[start:end], '\n'); newline != -1 {
+				end = start + newline
+			}
+			if end > start {
+				position := d.Position(start)
+				length := d.Position(end).Character - position.Character
+				character := position.Character
+				if position.Line == previous.Line {
+					character -= previous.Character
+				}
+				data = append(data, position.Line-previous.Line, character, length, int(token.Class)-1, 0)
+				previous = position
+			}
+			start = end + 1
+		}
+	}
+	return SemanticTokens{Data: data}
+}
@@ -0,0 +1,133 @@
+package djot_lsp
+
+import "encoding/json"
+
+// Subset of the Language Server Protocol 3.17 used by the server
+
+const (
+	fullSync      = 1
+	markdownKind  = "markdown"
+	moduleSymbol  = 2 // SymbolKind.Module
+	textKind      = 1 // CompletionItemKind.Text
+	referenceKind = 18
+	errorLevel    = 1 // DiagnosticSeverity.Error
+	warningLevel  = 2
+
+	parseError     = -32700
+	invalidRequest = -32600
+	methodNotFound = -32601
+	invalidParams  = -32602
+)
+
+type (
+	Position struct {
+		Line      int `json:"line"`
+		Character int `json:"character"` // in UTF-16 code units
+	}
+	Range struct {
+		Start Position `json:"start"`
+		End   Position `json:"end"`
+	}
+	Location struct {
+		Uri   string `json:"uri"`
+		Range Range  `json:"range"`
+	}
+	TextEdit struct {
+		Range   Range  `json:"range"`
+		NewText string `json:"newText"`
+	}
+	Diagnostic struct {
+		Range    Range  `json:"range"`
+		Severity int    `json:"severity"`
+		Code     string `json:"code"`
+		Source   string `json:"source"`
+		Message  string `json:"message"`
+	}
+	DocumentSymbol struct {
+		Name           string           `json:"name"`
+		Detail         string           `json:"detail,omitempty"`
+		Kind           int              `json:"kind"`
+		Range          Range            `json:"range"`
+		SelectionRange Range            `json:"selectionRange"`
+		Children       []DocumentSymbol `json:"children,omitempty"`
+	}
+	CompletionItem struct {
+		Label  string `json:"label"`
+		Kind   int    `json:"kind"`
+		Detail string `json:"detail,omitempty"`
+	}
+	MarkupContent struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	}
+	SemanticTokens struct {
+		Data []int `json:"data"` // groups of line delta, start delta, length, token type and modifiers
+	}
+	Hover struct {
+		Contents MarkupContent `json:"contents"`
+		Range    *Range        `json:"range,omitempty"`
+	}
+
+	textDocumentIdentifier struct {
+		Uri string `json:"uri"`
+	}
+	textDocumentPositionParams struct {
+		TextDocument textDocumentIdentifier `json:"textDocument"`
+		Position     Position               `json:"position"`
+	}
+	didOpenParams struct {
+		TextDocument struct {
+			Uri  string `json:"uri"`
+			Text string `json:"text"`
+		} `json:"textDocument"`
+	}
+	didChangeParams struct {
+		TextDocument   textDocumentIdentifier `json:"textDocument"`
+		ContentChanges []struct {
+			Range *Range `json:"range"`
+			Text  string `json:"text"`
+		} `json:"contentChanges"`
+	}
+	textDocumentParams struct {
+		TextDocument textDocumentIdentifier `json:"textDocument"`
+	}
+	publishDiagnosticsParams struct {
+		Uri         string       `json:"uri"`
+		Diagnostics []Diagnostic `json:"diagnostics"`
+	}
+
+	message struct {
+		JsonRpc string          `json:"jsonrpc"`
+		Id      json.RawMessage `json:"id,omitempty"`
+		Method  string          `json:"method,omitempty"`
+		Params  json.RawMessage `json:"params,omitempty"`
+		Result  json.RawMessage `json:"result,omitempty"`
+		Error   *ResponseError  `json:"error,omitempty"`
+	}
+	ResponseError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+)
+
+func (e *ResponseError) Error() string { return e.Message }
+
+// semanticTokenTypes are standard LSP token types of djot_tokenizer.SemanticClass values starting from DelimiterClass,
+// TextClass isn't reported
+var semanticTokenTypes = []string{"operator", "decorator", "namespace", "string", "number", "variable", "label", "macro", "enumMember"}
+
+var capabilities = map[string]any{
+	"capabilities": map[string]any{
+		"textDocumentSync":           fullSync,
+		"documentSymbolProvider":     true,
+		"definitionProvider":         true,
+		"hoverProvider":              true,
+		"documentFormattingProvider": true,
+		"completionProvider":         map[string]any{"triggerCharacters": []string{"[", "#", "^"}},
+		"semanticTokensProvider": map[string]any{
+			"legend": map[string]any{"tokenTypes": semanticTokenTypes, "tokenModifiers": []string{}},
+			"full":   true,
+		},
+	},
+	"serverInfo": map[string]any{"name": "djot"},
+}
@@ -0,0 +1,235 @@
+package djot_lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"md0.org/djot/djot_parser"
+)
+
+// Server implements djot language server over JSON-RPC 2.0 messages with Content-Length framing
+type Server struct {
+	documents map[string]*Document
+	options   djot_parser.ParseOptions
+	writer    io.Writer
+	shutdown  bool
+}
+
+// maxMessageLength limits Content-Length, so a broken header can't make the server allocate arbitrary memory
+const maxMessageLength = 64 << 20
+
+func NewServer() *Server {
+	return NewServerWithOptions(djot_parser.ParseOptions{})
+}
+
+// NewServerWithOptions creates server which parses documents with the options, so section ids and diagnostics are
+// the same as the converter and the linter give with these options
+func NewServerWithOptions(options djot_parser.ParseOptions) *Server {
+	return &Server{documents: make(map[string]*Document), options: options}
+}
+
+func readMessage(reader *bufio.Reader) (message, error) {
+	headers, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		return message{}, err
+	}
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil {
+		return message{}, fmt.Errorf("invalid Content-Length header: %w", err)
+	}
+	if length < 0 || length > maxMessageLength {
+		return message{}, fmt.Errorf("invalid Content-Length header: %v is out of range [0, %v]", length, maxMessageLength)
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(reader, body); err != nil {
+		return message{}, err
+	}
+	var m message
+	if err = json.Unmarshal(body, &m); err != nil {
+		return message{}, &ResponseError{Code: parseError, Message: err.Error()}
+	}
+	return m, nil
+}
+
+func writeMessage(writer io.Writer, m message) error {
+	m.JsonRpc = "2.0"
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "Content-Length: %v\r\n\r\n%s", len(body), body)
+	return err
+}
+
+// Serve handles messages until exit notification or end of input
+func (s *Server) Serve(reader io.Reader, writer io.Writer) error {
+	s.writer = writer
+	buffered := bufio.NewReader(reader)
+	for {
+		m, err := readMessage(buffered)
+		var responseErr *ResponseError
+		if errors.As(err, &responseErr) {
+			if err = writeMessage(writer, message{Id: json.RawMessage("null"), Error: responseErr}); err != nil {
+				return err
+			}
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if m.Method == "exit" {
+			return nil
+		}
+		result, err := s.handle(m.Method, m.Params)
+		if len(m.Id) == 0 {
+			continue // notifications have no response
+		}
+		response := message{Id: m.Id}
+		if err != nil {
+			if !errors.As(err, &responseErr) {
+				responseErr = &ResponseError{Code: invalidRequest, Message: err.Error()}
+			}
+			response.Error = responseErr
+		} else if response.Result, err = json.Marshal(result); err != nil {
+			return err
+		}
+		if err = writeMessage(writer, response); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) notify(method string, params any) {
+	encoded, err := json.Marshal(params)
+	if err == nil {
+		_ = writeMessage(s.writer, message{Method: method, Params: encoded})
+	}
+}
+
+func decode[T any](params json.RawMessage) (T, error) {
+	var value T
+	if err := json.Unmarshal(params, &value); err != nil {
+		return value, &ResponseError{Code: invalidParams, Message: err.Error()}
+	}
+	return value, nil
+}
+
+func (s *Server) document(uri string) (*Document, error) {
+	document, ok := s.documents[uri]
+	if !ok {
+		return nil, &ResponseError{Code: invalidParams, Message: "unknown document " + uri}
+	}
+	return document, nil
+}
+
+func (s *Server) handle(method string, params json.RawMessage) (any, error) {
+	if s.shutdown && method != "exit" {
+		return nil, &ResponseError{Code: invalidRequest, Message: "server is shut down"}
+	}
+	switch method {
+	case "initialize":
+		return capabilities, nil
+	case "initialized", "$/cancelRequest", "$/setTrace", "textDocument/didSave":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		p, err := decode[didOpenParams](params)
+		if err != nil {
+			return nil, err
+		}
+		s.open(p.TextDocument.Uri, []byte(p.TextDocument.Text))
+		return nil, nil
+	case "textDocument/didChange":
+		p, err := decode[didChangeParams](params)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range p.ContentChanges {
+			// only full synchronization is advertised so range is ignored
+			s.open(p.TextDocument.Uri, []byte(change.Text))
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		p, err := decode[textDocumentParams](params)
+		if err != nil {
+			return nil, err
+		}
+		delete(s.documents, p.TextDocument.Uri)
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{Uri: p.TextDocument.Uri, Diagnostics: []Diagnostic{}})
+		return nil, nil
+	case "textDocument/documentSymbol":
+		p, err := decode[textDocumentParams](params)
+		if err != nil {
+			return nil, err
+		}
+		document, err := s.document(p.TextDocument.Uri)
+		if err != nil {
+			return nil, err
+		}
+		return document.Symbols(), nil
+	case "textDocument/semanticTokens/full":
+		p, err := decode[textDocumentParams](params)
+		if err != nil {
+			return nil, err
+		}
+		document, err := s.document(p.TextDocument.Uri)
+		if err != nil {
+			return nil, err
+		}
+		return document.SemanticTokens(), nil
+	case "textDocument/formatting":
+		p, err := decode[textDocumentParams](params)
+		if err != nil {
+			return nil, err
+		}
+		document, err := s.document(p.TextDocument.Uri)
+		if err != nil {
+			return nil, err
+		}
+		return document.Format(), nil
+	case "textDocument/definition", "textDocument/hover", "textDocument/completion":
+		p, err := decode[textDocumentPositionParams](params)
+		if err != nil {
+			return nil, err
+		}
+		document, err := s.document(p.TextDocument.Uri)
+		if err != nil {
+			return nil, err
+		}
+		offset := document.Offset(p.Position)
+		switch method {
+		case "textDocument/definition":
+			if rng, ok := document.Definition(offset); ok {
+				return Location{Uri: p.TextDocument.Uri, Range: rng}, nil
+			}
+		case "textDocument/hover":
+			if hover, ok := document.Hover(offset); ok {
+				return hover, nil
+			}
+		default:
+			return document.Complete(offset), nil
+		}
+		return nil, nil
+	}
+	if strings.HasPrefix(method, "$/") {
+		return nil, nil // optional notifications and requests can be ignored
+	}
+	return nil, &ResponseError{Code: methodNotFound, Message: "method not found: " + method}
+}
+
+func (s *Server) open(uri string, text []byte) {
+	document := NewDocumentWithOptions(text, s.options)
+	s.documents[uri] = document
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{Uri: uri, Diagnostics: document.Diagnostics()})
+}
@@ -0,0 +1,229 @@
+package djot_lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/internal/testx"
+)
+
+// client talks to the in-process server through pipes
+type client struct {
+	t             *testing.T
+	writer        io.WriteCloser
+	incoming      chan message
+	id            int
+	notifications []message
+	done          chan error
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, writer: clientOut, incoming: make(chan message, 16), done: make(chan error, 1)}
+	go func() {
+		// pipes are synchronous so server notifications must be read concurrently
+		reader := bufio.NewReader(clientIn)
+		for {
+			m, err := readMessage(reader)
+			if err != nil {
+				close(c.incoming)
+				return
+			}
+			c.incoming <- m
+		}
+	}()
+	go func() {
+		err := NewServer().Serve(serverIn, serverOut)
+		_ = serverOut.Close()
+		c.done <- err
+	}()
+	t.Cleanup(func() {
+		_ = clientOut.Close()
+		testx.AssertNilError(t, "", <-c.done)
+	})
+	return c
+}
+
+func (c *client) send(id json.RawMessage, method string, params any) {
+	encoded, err := json.Marshal(params)
+	testx.AssertNilError(c.t, "", err)
+	testx.AssertNilError(c.t, "", writeMessage(c.writer, message{Id: id, Method: method, Params: encoded}))
+}
+
+func (c *client) notify(method string, params any) { c.send(nil, method, params) }
+
+// request sends request and decodes the result into T collecting notifications received in between
+func request[T any](c *client, method string, params any) (T, *ResponseError) {
+	c.id++
+	id := json.RawMessage(strconv.Itoa(c.id))
+	c.send(id, method, params)
+	for {
+		m, ok := <-c.incoming
+		testx.AssertTrue(c.t, "", ok)
+		if len(m.Id) == 0 {
+			c.notifications = append(c.notifications, m)
+			continue
+		}
+		testx.AssertEqual(c.t, "", string(id), string(m.Id))
+		var result T
+		if m.Error == nil {
+			testx.AssertNilError(c.t, "", json.Unmarshal(m.Result, &result))
+		}
+		return result, m.Error
+	}
+}
+
+const testUri = "file:///doc.djot"
+
+const testDocument = `# Intro
+
+See [the site][site], [setup](#Setup) and [^note].
+
+## Setup
+
+Run ` + "`make`" + ` [again][] and [oops][missing].
+
+[site]: https://example.com
+[again]: #Intro
+
+[^note]: Footnote text.
+`
+
+func at(line, character int) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": testUri}, "position": Position{Line: line, Character: character}}
+}
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+	initialize, _ := request[map[string]any](c, "initialize", map[string]any{})
+	testx.AssertEqual(t, "", "djot", initialize["serverInfo"].(map[string]any)["name"])
+	c.notify("initialized", map[string]any{})
+	c.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": testUri, "languageId": "djot", "version": 1, "text": testDocument}})
+
+	t.Run("symbols", func(t *testing.T) {
+		symbols, _ := request[[]DocumentSymbol](c, "textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": testUri}})
+		testx.AssertEqual(t, "", 1, len(symbols))
+		testx.AssertEqual(t, "", "Intro", symbols[0].Name)
+		testx.AssertEqual(t, "", Range{Start: Position{Line: 0, Character: 0}, End: Position{Line: 12, Character: 0}}, symbols[0].Range)
+		testx.AssertEqual(t, "", "Setup", symbols[0].Children[0].Name)
+		testx.AssertEqual(t, "", "#Setup", symbols[0].Children[0].Detail)
+	})
+	t.Run("semantic tokens", func(t *testing.T) {
+		tokens, _ := request[SemanticTokens](c, "textDocument/semanticTokens/full", map[string]any{"textDocument": map[string]any{"uri": testUri}})
+		testx.AssertEqual(t, "", []int{
+			0, 0, 2, 0, 0, // "# "
+			0, 2, 5, 2, 0, // "Intro"
+			2, 4, 1, 0, 0, // "["
+		}, tokens.Data[:15])
+	})
+	t.Run("diagnostics", func(t *testing.T) {
+		testx.AssertEqual(t, "", "textDocument/publishDiagnostics", c.notifications[0].Method)
+		var params publishDiagnosticsParams
+		testx.AssertNilError(t, "", json.Unmarshal(c.notifications[0].Params, &params))
+		testx.AssertEqual(t, "", []Diagnostic{{
+			Range:    Range{Start: Position{Line: 6, Character: 25}, End: Position{Line: 6, Character: 40}},
+			Severity: errorLevel,
+			Code:     "undefined-reference",
+			Source:   "djot",
+			Message:  "undefined reference 'missing'",
+		}}, params.Diagnostics)
+	})
+	t.Run("definition", func(t *testing.T) {
+		location, _ := request[Location](c, "textDocument/definition", at(2, 6))
+		testx.AssertEqual(t, "", Location{Uri: testUri, Range: Range{Start: Position{Line: 8}, End: Position{Line: 9}}}, location)
+		location, _ = request[Location](c, "textDocument/definition", at(2, 25))
+		testx.AssertEqual(t, "", Range{Start: Position{Line: 4}, End: Position{Line: 4, Character: 8}}, location.Range)
+		location, _ = request[Location](c, "textDocument/definition", at(2, 45))
+		testx.AssertEqual(t, "", Position{Line: 11}, location.Range.Start)
+		missing, _ := request[*Location](c, "textDocument/definition", at(0, 3))
+		testx.AssertEqual(t, "", (*Location)(nil), missing)
+	})
+	t.Run("hover", func(t *testing.T) {
+		hover, _ := request[Hover](c, "textDocument/hover", at(2, 6))
+		testx.AssertEqual(t, "", "`https://example.com`", hover.Contents.Value)
+		hover, _ = request[Hover](c, "textDocument/hover", at(2, 25))
+		testx.AssertEqual(t, "", "Section **Setup** (`#Setup`)", hover.Contents.Value)
+		hover, _ = request[Hover](c, "textDocument/hover", at(2, 45))
+		testx.AssertEqual(t, "", "Footnote text.", hover.Contents.Value)
+	})
+	t.Run("completion", func(t *testing.T) {
+		c.notify("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": testUri, "version": 2},
+			"contentChanges": []map[string]any{{"text": testDocument + "\n[x][s"}},
+		})
+		items, _ := request[[]CompletionItem](c, "textDocument/completion", at(14, 5))
+		labels := make([]string, 0)
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		testx.AssertEqual(t, "", []string{"again", "site", "Intro", "Setup"}, labels)
+	})
+	t.Run("formatting", func(t *testing.T) {
+		c.notify("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": testUri, "version": 3},
+			"contentChanges": []map[string]any{{"text": "\n# A  \n\n\n\ntext\t `a  \nb`\n\n``` \ncode  \n\n\n```\n\n"}},
+		})
+		edits, _ := request[[]TextEdit](c, "textDocument/formatting", map[string]any{"textDocument": map[string]any{"uri": testUri}})
+		testx.AssertEqual(t, "", 1, len(edits))
+		testx.AssertEqual(t, "", "# A\n\ntext\t `a  \nb`\n\n``` \ncode  \n\n\n```\n", edits[0].NewText)
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := request[any](c, "textDocument/unknown", map[string]any{})
+		testx.AssertEqual(t, "", methodNotFound, err.Code)
+		_, err = request[any](c, "textDocument/hover", map[string]any{"textDocument": map[string]any{"uri": "file:///other.djot"}})
+		testx.AssertEqual(t, "", invalidParams, err.Code)
+	})
+	_, _ = request[any](c, "shutdown", nil)
+	c.notify("exit", nil)
+}
+
+func TestDocumentOptions(t *testing.T) {
+	text := []byte("# Über Uns\n\nSee [Über Uns][] and [us](#uber-uns).\n")
+	document := NewDocumentWithOptions(text, djot_parser.ParseOptions{Slug: djot_parser.ASCIISlug})
+	testx.AssertEqual(t, "", "#uber-uns", document.Symbols()[0].Detail)
+	testx.AssertEqual(t, "", 0, len(document.Diagnostics()))
+	heading := Range{Start: Position{Line: 0}, End: Position{Line: 0, Character: 10}}
+	for _, offset := range []int{bytes.Index(text, []byte("[Über")) + 1, bytes.Index(text, []byte("[us")) + 1} {
+		rng, ok := document.Definition(offset)
+		testx.AssertTrue(t, "", ok)
+		testx.AssertEqual(t, "", heading, rng)
+		hover, ok := document.Hover(offset)
+		testx.AssertTrue(t, "", ok)
+		testx.AssertEqual(t, "", "Section **Über Uns** (`#uber-uns`)", hover.Contents.Value)
+	}
+	testx.AssertEqual(t, "", 1, len(NewDocument(text).Diagnostics()))
+}
+
+func TestPositions(t *testing.T) {
+	document := NewDocument([]byte("a😀b\nсd"))
+	testx.AssertEqual(t, "", Position{Line: 0, Character: 3}, document.Position(5))
+	testx.AssertEqual(t, "", 5, document.Offset(Position{Line: 0, Character: 3}))
+	testx.AssertEqual(t, "", Position{Line: 1, Character: 1}, document.Position(9))
+	testx.AssertEqual(t, "", 9, document.Offset(Position{Line: 1, Character: 1}))
+	testx.AssertEqual(t, "", 6, document.Offset(Position{Line: 0, Character: 100}))
+}
+
+func TestReadMessage(t *testing.T) {
+	for _, length := range []string{"-1", strconv.Itoa(maxMessageLength + 1), "x"} {
+		_, err := readMessage(bufio.NewReader(strings.NewReader("Content-Length: " + length + "\r\n\r\n{}")))
+		testx.AssertNotNil(t, length, err)
+	}
+	testx.AssertNotNil(t, "", NewServer().Serve(strings.NewReader("Content-Length: -5\r\n\r\n"), io.Discard))
+	m, err := readMessage(bufio.NewReader(strings.NewReader("Content-Length: 17\r\n\r\n{\"method\":\"exit\"}")))
+	testx.AssertNilError(t, "", err)
+	testx.AssertEqual(t, "", "exit", m.Method)
+}
+
+func TestFormatLineEndings(t *testing.T) {
+	edits := NewDocument([]byte("# A  \r\n\r\n\r\ntext\r\n```\r\ncode  \r\n```\r\n\r\n")).Format()
+	testx.AssertEqual(t, "", 1, len(edits))
+	testx.AssertEqual(t, "", "# A\r\n\r\ntext\r\n```\r\ncode  \r\n```\r\n", edits[0].NewText)
+	testx.AssertEqual(t, "", 0, len(NewDocument([]byte("# A\r\n\r\ntext\r\n")).Format()))
+}
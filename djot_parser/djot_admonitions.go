@@ -0,0 +1,93 @@
+package djot_parser
+
+import (
+	"strings"
+
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/html_writer"
+	"md0.org/djot/tokenizer"
+)
+
+const (
+	AdmonitionClass      = "admonition"
+	AdmonitionTitleClass = "admonition-title"
+	AdmonitionIconClass  = "admonition-icon"
+	AdmonitionTitleKey   = "title"
+)
+
+type (
+	// Admonition describes callout rendered for the div with the class
+	Admonition struct {
+		Title string // used when div has neither leading heading nor title attribute
+		Icon  string // content of the icon slot, can be empty to leave it for CSS
+	}
+	// Admonitions maps div classes to callouts, divs are rendered as usual if nil
+	Admonitions map[string]Admonition
+)
+
+var DefaultAdmonitions = Admonitions{
+	"note":    {Title: "Note", Icon: "ℹ"},
+	"tip":     {Title: "Tip", Icon: "💡"},
+	"warning": {Title: "Warning", Icon: "⚠"},
+	"danger":  {Title: "Danger", Icon: "⛔"},
+}
+
+// Find returns admonition for the first class of the div which has one
+func (admonitions Admonitions) Find(node TreeNode[DjotNode]) (Admonition, bool) {
+	if node.Type != DivNode {
+		return Admonition{}, false
+	}
+	for _, class := range strings.Fields(node.Attributes.Get(djot_tokenizer.DjotAttributeClassKey)) {
+		if admonition, ok := admonitions[class]; ok {
+			return admonition, true
+		}
+	}
+	return Admonition{}, false
+}
+
+// admonitionParts splits div into inline title and block body: title is taken from the leading heading (which is
+// removed from the body together with its section wrapper), title attribute or default title of the admonition
+func admonitionParts(node TreeNode[DjotNode], admonition Admonition) ([]TreeNode[DjotNode], []TreeNode[DjotNode]) {
+	body := node.Children
+	if len(body) > 0 && body[0].Type == SectionNode && len(body[0].Children) > 0 && body[0].Children[0].Type == HeadingNode {
+		body = append(append([]TreeNode[DjotNode]{}, body[0].Children...), body[1:]...)
+	}
+	if len(body) > 0 && body[0].Type == HeadingNode && len(body[0].Children) > 0 {
+		return body[0].Children, body[1:]
+	}
+	title := admonition.Title
+	if value, ok := node.Attributes.TryGet(AdmonitionTitleKey); ok {
+		title = value
+	}
+	return []TreeNode[DjotNode]{{Type: TextNode, Text: []byte(title)}}, node.Children
+}
+
+// AdmonitionConverter writes div as <aside role="note"> starting with the title paragraph with the icon slot
+func (state ConversionState) AdmonitionConverter(admonition Admonition, next func(c Children)) *html_writer.HtmlWriter {
+	title, body := admonitionParts(state.Node, admonition)
+	attributes := make([]tokenizer.AttributeEntry, 0)
+	for _, entry := range state.Node.Attributes.Entries() {
+		switch entry.Key {
+		case AdmonitionTitleKey:
+			continue
+		case djot_tokenizer.DjotAttributeClassKey:
+			entry.Value = AdmonitionClass + " " + entry.Value
+		}
+		attributes = append(attributes, entry)
+	}
+	attributes = append(attributes, tokenizer.AttributeEntry{Key: RoleKey, Value: "note"})
+	return state.Writer.InTag("aside", attributes...)(func() {
+		state.Writer.WriteString("\n")
+		state.Writer.InTag("p", tokenizer.AttributeEntry{Key: djot_tokenizer.DjotAttributeClassKey, Value: AdmonitionTitleClass})(func() {
+			state.Writer.InTag(
+				"span",
+				tokenizer.AttributeEntry{Key: djot_tokenizer.DjotAttributeClassKey, Value: AdmonitionIconClass},
+				tokenizer.AttributeEntry{Key: "aria-hidden", Value: "true"},
+			)(func() { state.Writer.WriteString(htmlReplacer.Replace(admonition.Icon)) })
+			next(title)
+		}).WriteString("\n")
+		if len(body) > 0 {
+			next(body)
+		}
+	}).WriteString("\n")
+}
@@ -0,0 +1,41 @@
+package djot_parser
+
+import (
+	"testing"
+
+	"md0.org/djot/html_writer"
+	"md0.org/djot/internal/testx"
+)
+
+func TestAdmonitions(t *testing.T) {
+	convert := func(document string, admonitions Admonitions) string {
+		context := NewConversionContext("html")
+		context.Options.Admonitions = admonitions
+		return context.ConvertDjotToHtml(&html_writer.HtmlWriter{}, BuildDjotAst([]byte(document))...)
+	}
+	t.Run("default title", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<aside class="admonition warning" id="w" role="note">
+<p class="admonition-title"><span class="admonition-icon" aria-hidden="true">⚠</span>Warning</p>
+<p>Mind the <em>gap</em></p>
+</aside>
+`, convert("{#w}\n::: warning\nMind the _gap_\n:::\n", DefaultAdmonitions))
+	})
+	t.Run("heading title", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<aside class="admonition tip" role="note">
+<p class="admonition-title"><span class="admonition-icon" aria-hidden="true"></span>Use <code>go vet</code></p>
+<p>Often.</p>
+</aside>
+`, convert("::: tip\n## Use `go vet`\n\nOften.\n:::\n", Admonitions{"tip": {Title: "Tip"}}))
+	})
+	t.Run("title attribute", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<aside class="admonition note" role="note">
+<p class="admonition-title"><span class="admonition-icon" aria-hidden="true">ℹ</span>Heads up</p>
+<p>a</p>
+</aside>
+`, convert("{title=\"Heads up\"}\n::: note\na\n:::\n", DefaultAdmonitions))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		testx.AssertEqual(t, "", "<div class=\"warning\">\n<p>a</p>\n</div>\n", convert("::: warning\na\n:::\n", nil))
+		testx.AssertEqual(t, "", "<div class=\"aside\">\n<p>a</p>\n</div>\n", convert("::: aside\na\n:::\n", DefaultAdmonitions))
+	})
+}
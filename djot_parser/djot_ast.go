@@ -3,9 +3,11 @@ package djot_parser
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"md0.org/djot/djot_tokenizer"
 	"md0.org/djot/tokenizer"
@@ -17,6 +19,7 @@ const (
 	HeadingLevelKey       = "$HeadingLevelKey"
 	SparseListNodeKey     = "$SparseListNodeKey"
 	DefinitionListItemKey = "$DefinitionListItemKey"
+	TableAlignmentKey     = "$TableAlignmentKey"
 
 	IdKey                  = "id"
 	RoleKey                = "role"
@@ -30,6 +33,7 @@ const (
 	CenterAlignment        = "center"
 	RightAlignment         = "right"
 	DefaultAlignment       = ""
+	EndnotesRole           = "doc-endnotes"
 )
 
 type DjotNode int
@@ -72,12 +76,18 @@ const (
 	LinkNode
 	ImageNode
 	SpanNode
+	FigureNode
+	FigureCaptionNode
 )
 
 func (n DjotNode) IsList() bool {
 	return n == UnorderedListNode || n == OrderedListNode || n == TaskListNode || n == DefinitionListNode
 }
 
+func (n DjotNode) IsInline() bool {
+	return n >= TextNode && n <= SpanNode
+}
+
 func (n DjotNode) String() string {
 	switch n {
 	case DocumentNode:
@@ -152,6 +162,12 @@ func (n DjotNode) String() string {
 		return "LinkNode"
 	case ImageNode:
 		return "ImageNode"
+	case SpanNode:
+		return "SpanNode"
+	case FigureNode:
+		return "FigureNode"
+	case FigureCaptionNode:
+		return "FigureCaptionNode"
 	default:
 		panic(fmt.Errorf("unexpected djot node: %d", n))
 	}
@@ -202,20 +218,57 @@ func normalizeLinkText(link []byte) []byte { return bytes.ReplaceAll(link, []byt
 type DjotContext struct {
 	References          map[string][]byte
 	ReferenceAttributes map[string]tokenizer.Attributes
-	FootnoteId          map[string]int
+	// FootnoteId numbers footnotes in the order of their first reference, unreferenced and undefined footnotes are missing
+	FootnoteId map[string]int
+	// SectionIds overrides ids of sections by the start of their heading token, id is created from heading text if missing
+	SectionIds map[int]string
+	Options    ParseOptions
+	footnotes  *footnoteReferences
 }
 
-func BuildDjotContext(document []byte, list tokenizer.TokenList[djot_tokenizer.DjotToken]) DjotContext {
-	context := DjotContext{
+// footnoteReferences is shared by all copies of the context, so references are collected through the whole document
+type footnoteReferences struct {
+	defined    map[string]bool
+	references []footnoteReference // in the document order
+}
+
+type footnoteReference struct {
+	label string
+	owner string // label of the footnote which contains the reference, empty for the text
+}
+
+func newDjotContext() DjotContext {
+	return DjotContext{
 		References:          make(map[string][]byte),
 		ReferenceAttributes: make(map[string]tokenizer.Attributes),
 		FootnoteId:          make(map[string]int),
+		footnotes: &footnoteReferences{
+			defined:    make(map[string]bool),
+			references: make([]footnoteReference, 0),
+		},
 	}
+}
 
-	footnoteId := 1
+func BuildDjotContext(document []byte, list tokenizer.TokenList[djot_tokenizer.DjotToken]) DjotContext {
+	return BuildDjotContextWithOptions(document, list, ParseOptions{})
+}
 
-	i := 0
+func BuildDjotContextWithOptions(document []byte, list tokenizer.TokenList[djot_tokenizer.DjotToken], options ParseOptions) DjotContext {
+	context := newDjotContext()
+	context.Options = options
+	context.add(document, list)
+	context.numberFootnotes()
+	return context
+}
+
+// add collects definitions and footnote references of the document into context, numberFootnotes must be called
+// after all documents are added
+func (context DjotContext) add(document []byte, list tokenizer.TokenList[djot_tokenizer.DjotToken]) {
+	i, owner, ownerEnd := 0, "", 0
 	for i < len(list) {
+		if i > ownerEnd {
+			owner = ""
+		}
 		var attributes tokenizer.Attributes
 		for i < len(list) && list[i].Type == djot_tokenizer.Attribute {
 			attributes.MergeWith(list[i].Attributes)
@@ -235,19 +288,108 @@ func BuildDjotContext(document []byte, list tokenizer.TokenList[djot_tokenizer.D
 			context.References[reference] = link
 			context.ReferenceAttributes[reference] = attributes
 		case djot_tokenizer.FootnoteDefBlock:
-			reference := openToken.Attributes.Get(djot_tokenizer.ReferenceKey)
-			context.FootnoteId[reference] = footnoteId
-			footnoteId++
+			owner, ownerEnd = openToken.Attributes.Get(djot_tokenizer.ReferenceKey), i+openToken.JumpToPair
+			context.footnotes.defined[owner] = true
+		case djot_tokenizer.FootnoteReferenceInline:
+			reference := footnoteReference{label: string(document[openToken.End:closeToken.Start]), owner: owner}
+			context.footnotes.references = append(context.footnotes.references, reference)
 		case djot_tokenizer.HeadingBlock:
-			headerId := CreateSectionId(string(selectText(document, list[i+1:i+openToken.JumpToPair])))
-			// don't overwrite reference if any
-			if _, ok := context.References[headerId]; !ok {
-				context.References[headerId] = []byte("#" + headerId)
+			text := string(SelectText(document, list[i+1:i+openToken.JumpToPair]))
+			headerId := context.Options.SectionId(text)
+			// heading can be referenced by its text or id, don't overwrite reference if any
+			for _, label := range []string{strings.TrimSpace(text), headerId} {
+				if _, ok := context.References[label]; !ok {
+					context.References[label] = []byte("#" + headerId)
+				}
 			}
 		}
 		i++
 	}
-	return context
+	if context.Options.CrossReferences != nil {
+		context.addIds(list)
+	}
+}
+
+// numberFootnotes assigns numbers to the defined footnotes in the order of their first reference; only references
+// from the text and from the numbered footnotes count, so references inside of the omitted footnotes leave no gaps
+func (context DjotContext) numberFootnotes() {
+	reachable := map[string]bool{"": true}
+	for changed := true; changed; {
+		changed = false
+		for _, reference := range context.footnotes.references {
+			if reachable[reference.owner] && !reachable[reference.label] && context.footnotes.defined[reference.label] {
+				reachable[reference.label], changed = true, true
+			}
+		}
+	}
+	footnoteId := 1
+	for _, reference := range context.footnotes.references {
+		if _, ok := context.FootnoteId[reference.label]; !ok && reachable[reference.owner] && reachable[reference.label] {
+			context.FootnoteId[reference.label] = footnoteId
+			footnoteId++
+		}
+	}
+}
+
+// footnoteReferenceId returns id of the reference to the footnote: first one is fnref1 and following are fnref1-2,
+// fnref1-3 and so on
+func footnoteReferenceId(footnoteId, reference int) string {
+	if reference <= 1 {
+		return fmt.Sprintf("fnref%v", footnoteId)
+	}
+	return fmt.Sprintf("fnref%v-%v", footnoteId, reference)
+}
+
+// linkFootnotes numbers footnote references of the whole document in their order and gives every footnote backlink
+// to each of its references; numbering is derived from the AST, so references which weren't built (like ones from
+// the omitted footnotes) don't get backlinks and building the document again gives the same ids
+func linkFootnotes(ast []TreeNode[DjotNode]) []TreeNode[DjotNode] {
+	references := make(map[string]int)
+	var number func(nodes []TreeNode[DjotNode])
+	number = func(nodes []TreeNode[DjotNode]) {
+		for i := range nodes {
+			if isFootnoteReference(nodes[i]) {
+				href := nodes[i].Attributes.Get(LinkHrefKey)
+				references[href]++
+				footnoteId, _ := strconv.Atoi(strings.TrimPrefix(href, "#fn"))
+				nodes[i].Attributes.Set(IdKey, footnoteReferenceId(footnoteId, references[href]))
+			}
+			number(nodes[i].Children)
+		}
+	}
+	number(ast)
+	var link func(nodes []TreeNode[DjotNode])
+	link = func(nodes []TreeNode[DjotNode]) {
+		for i := range nodes {
+			node := &nodes[i]
+			if node.Type != ListItemNode || len(node.Children) != 1 || node.Children[0].Type != FootnoteDefNode {
+				link(node.Children)
+				continue
+			}
+			footnoteId, _ := strconv.Atoi(strings.TrimPrefix(node.Attributes.Get(IdKey), "fn"))
+			backlinks := make([]TreeNode[DjotNode], 0, 1)
+			for n := 1; n <= references["#"+node.Attributes.Get(IdKey)]; n++ {
+				backlinks = append(backlinks, TreeNode[DjotNode]{
+					Type:     LinkNode,
+					Children: []TreeNode[DjotNode]{{Type: TextNode, Text: []byte("↩︎︎")}},
+					Attributes: tokenizer.NewAttributes(
+						tokenizer.AttributeEntry{Key: LinkHrefKey, Value: "#" + footnoteReferenceId(footnoteId, n)},
+						tokenizer.AttributeEntry{Key: RoleKey, Value: "doc-backlink"},
+					),
+				})
+			}
+			children := &node.Children[0].Children
+			switch last := len(*children) - 1; {
+			case len(backlinks) == 0:
+			case last >= 0 && (*children)[last].Type == ParagraphNode:
+				(*children)[last].Children = append((*children)[last].Children, backlinks...)
+			default:
+				*children = append(*children, TreeNode[DjotNode]{Type: ParagraphNode, Children: backlinks})
+			}
+		}
+	}
+	link(ast)
+	return ast
 }
 
 func isSpaceToken(document []byte, token tokenizer.Token[djot_tokenizer.DjotToken]) bool {
@@ -278,21 +420,34 @@ func detectQuoteDirection(document []byte, position int) QuoteDirection {
 	if position == len(document)-1 {
 		return CloseQuote
 	}
-	if unicode.IsSpace(rune(document[position-1])) {
+	before, _ := utf8.DecodeLastRune(document[:position])
+	after, _ := utf8.DecodeRune(document[position+1:])
+	if unicode.IsSpace(before) {
 		return OpenQuote
 	}
-	if unicode.IsSpace(rune(document[position+1])) {
+	if unicode.IsSpace(after) {
 		return CloseQuote
 	}
-	if unicode.IsPunct(rune(document[position-1])) {
+	if unicode.IsPunct(before) {
 		return OpenQuote
 	}
-	if unicode.IsPunct(rune(document[position+1])) {
+	if unicode.IsPunct(after) {
 		return CloseQuote
 	}
 	return CloseQuote
 }
 
+// isApostrophe reports whether single quote is inside of the word (like in "don't"), it's written the same way
+// regardless of the quote style
+func isApostrophe(document []byte, position int) bool {
+	if position == 0 || position == len(document)-1 {
+		return false
+	}
+	before, _ := utf8.DecodeLastRune(document[:position])
+	after, _ := utf8.DecodeRune(document[position+1:])
+	return unicode.IsLetter(before) && unicode.IsLetter(after)
+}
+
 func trimPadding(document []byte, list tokenizer.TokenList[djot_tokenizer.DjotToken]) tokenizer.TokenList[djot_tokenizer.DjotToken] {
 	start, end := 0, len(list)
 	for start < end && isSpaceToken(document, list[start]) {
@@ -307,7 +462,8 @@ func trimPadding(document []byte, list tokenizer.TokenList[djot_tokenizer.DjotTo
 	return nil
 }
 
-func selectText(document []byte, list tokenizer.TokenList[djot_tokenizer.DjotToken]) []byte {
+// SelectText concatenates plain text tokens, ignoring markup
+func SelectText(document []byte, list tokenizer.TokenList[djot_tokenizer.DjotToken]) []byte {
 	text := make([]byte, 0)
 	for _, token := range list {
 		if token.Type == djot_tokenizer.None || token.Type == djot_tokenizer.SmartSymbolInline {
@@ -395,9 +551,26 @@ func detectListProps(document []byte, token tokenizer.Token[djot_tokenizer.DjotT
 }
 
 func BuildDjotAst(document []byte) []TreeNode[DjotNode] {
+	return BuildDjotAstWithOptions(document, ParseOptions{})
+}
+
+func BuildDjotAstWithOptions(document []byte, options ParseOptions) []TreeNode[DjotNode] {
 	tokens := djot_tokenizer.BuildDjotTokens(document)
-	context := BuildDjotContext(document, tokens)
-	ast := buildDjotAst(document, context, DjotLocalContext{}, tokens)
+	context := BuildDjotContextWithOptions(document, tokens, options)
+	return options.transform(linkFootnotes(buildDjotAst(document, context, DjotLocalContext{}, tokens)))
+}
+
+// transform applies options which work with the built AST
+func (options ParseOptions) transform(ast []TreeNode[DjotNode]) []TreeNode[DjotNode] {
+	if options.Figures {
+		ast = Figures(ast)
+	}
+	if options.Linkify {
+		ast = Linkify(ast)
+	}
+	if options.CrossReferences != nil {
+		ast = NumberCrossReferences(ast, *options.CrossReferences)
+	}
 	return ast
 }
 
@@ -455,13 +628,21 @@ func buildDjotAst(
 		i, previous := 0, -1
 		for i < len(list) {
 			openToken := list[i]
+			if len(groupElements) > 0 && groupElements[len(groupElements)-1].Type == TableNode &&
+				openToken.Type != djot_tokenizer.Attribute &&
+				openToken.Type != djot_tokenizer.PipeTableBlock &&
+				openToken.Type != djot_tokenizer.PipeTableCaptionBlock {
+				// only rows and caption belong to the table, everything else follows it
+				groupElementsPop[i]++
+				groupElements = groupElements[:len(groupElements)-1]
+			}
 			switch openToken.Type {
 			case djot_tokenizer.PipeTableCaptionBlock:
 				groupElementsInsert[activeTableProps.TableIndex] = &TreeNode[DjotNode]{
 					Type: TableNode,
 					Children: []TreeNode[DjotNode]{{
 						Type:     TableCaptionNode,
-						Children: buildDjotAst(document, context, DjotLocalContext{TextNode: true}, list[i+1:i+openToken.JumpToPair]),
+						Children: buildDjotAst(document, context, DjotLocalContext{TextNode: true}, trimPadding(document, list[i+1:i+openToken.JumpToPair])),
 					}},
 				}
 			case djot_tokenizer.PipeTableBlock:
@@ -487,7 +668,13 @@ func buildDjotAst(
 					}
 				}
 				if columns != len(activeTableProps.Alignments) {
-					groupElementsInsert[i] = &TreeNode[DjotNode]{Type: TableNode}
+					for len(groupElements) > 0 && (groupElements[len(groupElements)-1].Type == TableNode || groupElements[len(groupElements)-1].Type.IsList()) {
+						groupElementsPop[i]++
+						groupElements = groupElements[:len(groupElements)-1]
+					}
+					tableNode := &TreeNode[DjotNode]{Type: TableNode}
+					groupElementsInsert[i] = tableNode
+					groupElements = append(groupElements, tableNode)
 					activeTableProps = TableProps{TableIndex: i, IsHeader: false, Alignments: make([]string, columns)}
 				}
 				if len(alignments) == columns {
@@ -513,10 +700,14 @@ func buildDjotAst(
 					groupElements = groupElements[:len(groupElements)-1]
 					pop++
 				}
-				groupElementsPop[i] = pop
+				groupElementsPop[i] += pop
+				sectionId, ok := context.SectionIds[openToken.Start]
+				if !ok {
+					sectionId = context.Options.SectionId(string(SelectText(document, list[i+1:i+openToken.JumpToPair])))
+				}
 				sectionNode := TreeNode[DjotNode]{Type: SectionNode, Attributes: tokenizer.NewAttributes(tokenizer.AttributeEntry{
 					Key:   "id",
-					Value: CreateSectionId(string(selectText(document, list[i+1:i+openToken.JumpToPair]))),
+					Value: sectionId,
 				})}
 				groupElementsInsert[i] = &sectionNode
 				groupElements = append(groupElements, &sectionNode)
@@ -524,7 +715,7 @@ func buildDjotAst(
 				currentList, currentStart := detectListProps(document, openToken)
 				// reset group only if last active group is the List of another type (markers, style, etc.)
 				if len(groupElements) > 0 && activeListNode != nil && activeList != currentList {
-					groupElementsPop[i] = 1
+					groupElementsPop[i]++
 					groupElements = groupElements[:len(groupElements)-1]
 				}
 				if len(groupElements) == 0 || !groupElements[len(groupElements)-1].Type.IsList() {
@@ -548,7 +739,7 @@ func buildDjotAst(
 				activeListLastItemSparse = list[i+openToken.JumpToPair-1].End < list[i+openToken.JumpToPair].Start
 			default:
 				if len(groupElements) > 0 && groupElements[len(groupElements)-1].Type.IsList() {
-					groupElementsPop[i] = 1
+					groupElementsPop[i]++
 					groupElements = groupElements[:len(groupElements)-1]
 				}
 			}
@@ -593,12 +784,31 @@ func buildDjotAst(
 			if insert, ok := groupElementsInsert[i]; ok {
 				_, isSparseList = insert.Attributes.TryGet(SparseListNodeKey)
 				insertedNodeType = insert.Type
+				if insert.Type == TableNode {
+					// attributes before the first row belong to the whole table
+					insert.Attributes.MergeWith(attributes)
+				}
 
 				*nodesRef = append(*nodesRef, *insert)
 				nodesRef = &(*nodesRef)[len(*nodesRef)-1].Children
 				groups = append(groups, nodesRef)
 			}
+			siblings, appended := nodesRef, len(*nodesRef)
+			rng := tokenizer.Range{Start: openToken.Start, End: closeToken.End}
 
+			if format, ok := csvFormat(DivNode, attributes); ok && context.Options.CSV && openToken.Type == djot_tokenizer.DivBlock {
+				// content of the div is taken from the source as paragraphs don't keep it as is
+				content := document[openToken.End:closeToken.Start]
+				if newline := bytes.IndexByte(content, '\n'); newline >= 0 {
+					content = content[newline+1:]
+				}
+				if table, ok := csvBlockTable(content, format, attributes); ok {
+					*nodesRef = append(*nodesRef, table)
+					setRanges((*siblings)[appended:], rng)
+					i = nextI
+					continue
+				}
+			}
 			switch openToken.Type {
 			case
 				djot_tokenizer.DocumentBlock,
@@ -636,6 +846,13 @@ func buildDjotAst(
 				)
 				if suffix, ok := strings.CutPrefix(lang, "="); ok {
 					attributes.Set(RawBlockFormatKey, suffix)
+					if format, ok := csvFormat(RawNode, attributes); ok && context.Options.CSV {
+						content := TreeNode[DjotNode]{Children: internal}.FullText()
+						if table, ok := csvBlockTable(content, format, attributes); ok {
+							*nodesRef = append(*nodesRef, table)
+							break
+						}
+					}
 					*nodesRef = append(*nodesRef, TreeNode[DjotNode]{
 						Type:       RawNode,
 						Children:   internal,
@@ -715,8 +932,15 @@ func buildDjotAst(
 					Attributes: attributes,
 				})
 			case djot_tokenizer.FootnoteReferenceInline:
-				footnoteId := context.FootnoteId[string(document[openToken.End:closeToken.Start])]
-				attributes.Set(IdKey, fmt.Sprintf("fnref%v", footnoteId))
+				reference := string(document[openToken.End:closeToken.Start])
+				footnoteId, ok := context.FootnoteId[reference]
+				if !ok {
+					// reference to undefined footnote is kept as is, like undefined link reference
+					*nodesRef = append(*nodesRef, TreeNode[DjotNode]{Type: TextNode, Text: document[openToken.Start:closeToken.End]})
+					break
+				}
+				// id is numbered by linkFootnotes once all references are built
+				attributes.Set(IdKey, footnoteReferenceId(footnoteId, 1))
 				attributes.Set(LinkHrefKey, fmt.Sprintf("#fn%v", footnoteId))
 				attributes.Set(RoleKey, "doc-noteref")
 				*nodesRef = append(*nodesRef, TreeNode[DjotNode]{
@@ -743,19 +967,20 @@ func buildDjotAst(
 				}
 				switch nextToken.Type {
 				case djot_tokenizer.LinkUrlInline:
-					attributes.Set(ImgAltKey, string(selectText(document, list[i+1:i+openToken.JumpToPair])))
+					attributes.Set(ImgAltKey, string(SelectText(document, list[i+1:i+openToken.JumpToPair])))
 					attributes.Set(ImgSrcKey, string(normalizeLinkText(document[nextToken.End:list[nextI+nextToken.JumpToPair].Start])))
 					*nodesRef = append(*nodesRef, TreeNode[DjotNode]{
 						Type:       ImageNode,
 						Attributes: attributes,
+						Children:   buildDjotAst(document, context, DjotLocalContext{TextNode: true}, list[i+1:i+openToken.JumpToPair]),
 					})
 					nextI += nextToken.JumpToPair + 1
 				case djot_tokenizer.LinkReferenceInline:
 					reference := normalizeLinkText(document[nextToken.End:list[nextI+nextToken.JumpToPair].Start])
 					if len(reference) == 0 {
-						reference = selectText(document, list[i+1:i+openToken.JumpToPair])
+						reference = SelectText(document, list[i+1:i+openToken.JumpToPair])
 					}
-					attributes.Set(ImgAltKey, string(selectText(document, list[i+1:i+openToken.JumpToPair])))
+					attributes.Set(ImgAltKey, string(SelectText(document, list[i+1:i+openToken.JumpToPair])))
 					if href := string(normalizeLinkText(context.References[string(reference)])); href != "" {
 						attributes.Set(ImgSrcKey, href)
 						attributes.MergeWith(context.ReferenceAttributes[string(reference)])
@@ -763,6 +988,7 @@ func buildDjotAst(
 					*nodesRef = append(*nodesRef, TreeNode[DjotNode]{
 						Type:       ImageNode,
 						Attributes: attributes,
+						Children:   buildDjotAst(document, context, DjotLocalContext{TextNode: true}, list[i+1:i+openToken.JumpToPair]),
 					})
 					nextI += nextToken.JumpToPair + 1
 				default:
@@ -794,7 +1020,7 @@ func buildDjotAst(
 				} else if nextToken.Type == djot_tokenizer.LinkReferenceInline {
 					reference := normalizeLinkText(document[nextToken.End:list[nextI+nextToken.JumpToPair].Start])
 					if len(reference) == 0 {
-						reference = selectText(document, list[i+1:i+openToken.JumpToPair])
+						reference = SelectText(document, list[i+1:i+openToken.JumpToPair])
 					}
 					if href := string(normalizeLinkText(context.References[string(reference)])); href != "" {
 						attributes.Set(LinkHrefKey, href)
@@ -837,15 +1063,23 @@ func buildDjotAst(
 				textString := strings.Trim(string(textBytes), "{}")
 				if localContext.TextNode {
 					quoteDirection := detectQuoteDirection(document, openToken.Start)
-					if openToken.Type == djot_tokenizer.SmartSymbolInline {
-						if textString == "\"" && quoteDirection == OpenQuote {
-							textBytes = []byte(`“`)
+					quotes := context.Options.Quotes
+					if quotes == (QuoteStyle{}) {
+						quotes = EnglishQuotes
+					}
+					if context.Options.NoSmartPunctuation {
+						textBytes = []byte(textString)
+					} else if openToken.Type == djot_tokenizer.SmartSymbolInline {
+						if textString == "'" && string(textBytes) == "'" && isApostrophe(document, openToken.Start) {
+							textBytes = []byte(`’`)
+						} else if textString == "\"" && quoteDirection == OpenQuote {
+							textBytes = []byte(quotes.OpenDouble)
 						} else if textString == "\"" && quoteDirection == CloseQuote {
-							textBytes = []byte(`”`)
+							textBytes = []byte(quotes.CloseDouble)
 						} else if textString == "'" && quoteDirection == OpenQuote {
-							textBytes = []byte(`‘`)
+							textBytes = []byte(quotes.OpenSingle)
 						} else if textString == "'" && quoteDirection == CloseQuote {
-							textBytes = []byte(`’`)
+							textBytes = []byte(quotes.CloseSingle)
 						} else if textString == "..." {
 							textBytes = []byte(`…`)
 						} else if strings.Count(textString, "-") == len(textString) {
@@ -903,20 +1137,14 @@ func buildDjotAst(
 					}
 				}
 			case djot_tokenizer.FootnoteDefBlock:
-				footnoteId := context.FootnoteId[attributes.Get(djot_tokenizer.ReferenceKey)]
-				children := buildDjotAst(document, context, DjotLocalContext{}, list[i+1:i+openToken.JumpToPair])
-				attributes.Set(LinkHrefKey, fmt.Sprintf("#fnref%v", footnoteId))
-				attributes.Set("role", "doc-backlink")
-				backrefLinkNode := TreeNode[DjotNode]{
-					Type:       LinkNode,
-					Children:   []TreeNode[DjotNode]{{Type: TextNode, Text: []byte("↩︎︎")}},
-					Attributes: attributes,
-				}
-				if len(children) > 0 && children[len(children)-1].Type == ParagraphNode {
-					children[len(children)-1].Children = append(children[len(children)-1].Children, backrefLinkNode)
-				} else {
-					children = append(children, TreeNode[DjotNode]{Type: ParagraphNode, Children: []TreeNode[DjotNode]{backrefLinkNode}})
+				reference := attributes.Get(djot_tokenizer.ReferenceKey)
+				footnoteId, ok := context.FootnoteId[reference]
+				if !ok {
+					// footnote without references is omitted
+					break
 				}
+				// backlinks are added by linkFootnotes
+				children := buildDjotAst(document, context, DjotLocalContext{}, list[i+1:i+openToken.JumpToPair])
 				footnotes = append(footnotes, TreeNode[DjotNode]{
 					Type: ListItemNode,
 					Children: []TreeNode[DjotNode]{{
@@ -925,12 +1153,15 @@ func buildDjotAst(
 						Attributes: attributes,
 					}},
 					Attributes: tokenizer.NewAttributes(tokenizer.AttributeEntry{Key: "id", Value: fmt.Sprintf("fn%v", footnoteId)}),
+					Range:      rng,
 				})
 			case djot_tokenizer.PipeTableBlock:
 				if !assignedTableProps[i].Ignore {
+					rowAttributes, row := splitTableRowAttributes(document, trimPadding(document, list[i+1:i+openToken.JumpToPair]))
 					*nodesRef = append(*nodesRef, TreeNode[DjotNode]{
-						Type:     TableRowNode,
-						Children: buildDjotAst(document, context, DjotLocalContext{TextNode: true, TableNode: true, TableProps: assignedTableProps[i]}, trimPadding(document, list[i+1:i+openToken.JumpToPair])),
+						Type:       TableRowNode,
+						Children:   buildDjotAst(document, context, DjotLocalContext{TextNode: true, TableNode: true, TableProps: assignedTableProps[i]}, row),
+						Attributes: rowAttributes,
 					})
 				}
 			case djot_tokenizer.PipeTableSeparator:
@@ -945,11 +1176,13 @@ func buildDjotAst(
 					}
 					tableCellId++
 					if alignment != DefaultAlignment {
-						attributes.Set("style", fmt.Sprintf("text-align: %v;", alignment))
+						attributes.Set(TableAlignmentKey, alignment)
 					}
+					cellAttributes, cell := splitTableCellAttributes(document, trimPadding(document, list[i+1:i+openToken.JumpToPair]))
+					attributes.MergeWith(cellAttributes)
 					*nodesRef = append(*nodesRef, TreeNode[DjotNode]{
 						Type:       nodeType,
-						Children:   buildDjotAst(document, context, DjotLocalContext{TextNode: true}, trimPadding(document, list[i+1:i+openToken.JumpToPair])),
+						Children:   buildDjotAst(document, context, DjotLocalContext{TextNode: true}, cell),
 						Attributes: attributes,
 					})
 				} else {
@@ -971,18 +1204,59 @@ func buildDjotAst(
 					}
 				}
 			}
+			setRanges((*siblings)[appended:], rng)
 			i = nextI
 		}
 	}
 	if len(footnotes) > 0 {
-		nodes = append(nodes, TreeNode[DjotNode]{
-			Type:       SectionNode,
-			Attributes: tokenizer.NewAttributes(tokenizer.AttributeEntry{Key: "role", Value: "doc-endnotes"}),
-			Children: []TreeNode[DjotNode]{
-				{Type: ThematicBreakNode},
-				{Type: OrderedListNode, Children: footnotes},
-			},
-		})
+		nodes = append(nodes, endnotesSection(footnotes))
 	}
 	return nodes
 }
+
+// setRanges sets range of the token to the nodes built from it, nodes built by the nested calls keep their own ranges
+func setRanges(nodes []TreeNode[DjotNode], rng tokenizer.Range) {
+	for i := range nodes {
+		if nodes[i].Range == (tokenizer.Range{}) {
+			nodes[i].Range = rng
+		}
+	}
+}
+
+// endnotesSection wraps footnotes ordered by their numbers; list numbers follow numbers of the footnotes, so when
+// footnotes are split into several sections the list gets start and skipped numbers get value of the item
+func endnotesSection(footnotes []TreeNode[DjotNode]) TreeNode[DjotNode] {
+	sort.SliceStable(footnotes, func(i, j int) bool { return footnoteNumber(footnotes[i]) < footnoteNumber(footnotes[j]) })
+	var attributes tokenizer.Attributes
+	items := make([]TreeNode[DjotNode], len(footnotes))
+	for i, footnote := range footnotes {
+		n := footnoteNumber(footnote)
+		switch {
+		case i == 0 && n > 1:
+			attributes.Set("start", strconv.Itoa(n))
+		case i > 0 && n > footnoteNumber(footnotes[i-1])+1:
+			// attributes are copied as footnotes are shared with the AST
+			footnote.Attributes = tokenizer.NewAttributes(append(footnote.Attributes.Entries(), tokenizer.AttributeEntry{Key: "value", Value: strconv.Itoa(n)})...)
+		}
+		items[i] = footnote
+	}
+	return TreeNode[DjotNode]{
+		Type:       SectionNode,
+		Attributes: tokenizer.NewAttributes(tokenizer.AttributeEntry{Key: RoleKey, Value: EndnotesRole}),
+		Children: []TreeNode[DjotNode]{
+			{Type: ThematicBreakNode},
+			{Type: OrderedListNode, Children: items, Attributes: attributes},
+		},
+	}
+}
+
+// footnoteNumber returns number of the footnote list item from its id, like 2 for fn2 (or file1-fn2 with a prefix)
+func footnoteNumber(footnote TreeNode[DjotNode]) int {
+	id := footnote.Attributes.Get(IdKey)
+	start := strings.LastIndex(id, "fn")
+	if start == -1 {
+		return 0
+	}
+	n, _ := strconv.Atoi(id[start+len("fn"):])
+	return n
+}
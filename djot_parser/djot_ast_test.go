@@ -112,7 +112,7 @@ func TestDjotDocExample(t *testing.T) {
 				fmt.Sprintf("invalid html (%v != %v), djot tokens: %v",
 					string(htmlExample),
 					result,
-					djot_tokenizer.BuildDjotTokens(djotExample)),
+					djot_tokenizer.DumpDjotTokens(djotExample, djot_tokenizer.BuildDjotTokens(djotExample))),
 				string(htmlExample),
 				result,
 			)
@@ -138,3 +138,93 @@ func TestManualExamples(t *testing.T) {
 		t.Log(result)
 	})
 }
+
+func TestFootnotes(t *testing.T) {
+	result := printDjot(`First[^b], second[^a], again[^b] and missing[^x].
+
+[^a]: Note a.
+
+[^b]: Note b.
+
+[^c]: Unused note referencing[^a].
+`)
+	testx.AssertEqual(t, "", `<p>First<a id="fnref1" href="#fn1" role="doc-noteref"><sup>1</sup></a>, second<a id="fnref2" href="#fn2" role="doc-noteref"><sup>2</sup></a>, again<a id="fnref1-2" href="#fn1" role="doc-noteref"><sup>1</sup></a> and missing[^x].</p>
+<section role="doc-endnotes">
+<hr>
+<ol>
+<li id="fn1">
+<p>Note b.<a href="#fnref1" role="doc-backlink">↩︎︎</a><a href="#fnref1-2" role="doc-backlink">↩︎︎</a></p>
+</li>
+<li id="fn2">
+<p>Note a.<a href="#fnref2" role="doc-backlink">↩︎︎</a></p>
+</li>
+</ol>
+</section>
+`, result)
+}
+
+func TestFootnotesReachableNumbering(t *testing.T) {
+	// [^d] is referenced only from the omitted footnote, [^e] from the numbered one
+	result := printDjot(`Text[^a].
+
+[^u]: Unused note referencing[^d].
+
+[^a]: Note a[^e].
+
+[^d]: Note d.
+
+[^e]: Note e.
+`)
+	testx.AssertEqual(t, "", `<p>Text<a id="fnref1" href="#fn1" role="doc-noteref"><sup>1</sup></a>.</p>
+<section role="doc-endnotes">
+<hr>
+<ol>
+<li id="fn1">
+<p>Note a<a id="fnref2" href="#fn2" role="doc-noteref"><sup>2</sup></a>.<a href="#fnref1" role="doc-backlink">↩︎︎</a></p>
+</li>
+<li id="fn2">
+<p>Note e.<a href="#fnref2" role="doc-backlink">↩︎︎</a></p>
+</li>
+</ol>
+</section>
+`, result)
+}
+
+func TestSmartPunctuation(t *testing.T) {
+	convert := func(text string, options ParseOptions) string {
+		ast := BuildDjotAstWithOptions([]byte(text), options)
+		return NewConversionContext("html", DefaultConversionRegistry).ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...)
+	}
+	t.Run("utf-8 neighbours", func(t *testing.T) {
+		testx.AssertEqual(t, "", "<p>a&mdash;&ldquo;b&rdquo;&mdash;c &ldquo;привет&rdquo;</p>\n", convert(`a—"b"—c "привет"`, ParseOptions{}))
+	})
+	t.Run("locales", func(t *testing.T) {
+		german, ok := QuoteStyleForLocale("de_DE")
+		testx.AssertTrue(t, "", ok)
+		testx.AssertEqual(t, "", "<p>Er sagt „geht&rsquo;s?&ldquo; und ‚ja&lsquo;</p>\n", convert(`Er sagt "geht's?" und 'ja'`, ParseOptions{Quotes: german}))
+		french, ok := QuoteStyleForLocale("fr")
+		testx.AssertTrue(t, "", ok)
+		testx.AssertEqual(t, "", "<p>« Oui »</p>\n", convert(`"Oui"`, ParseOptions{Quotes: french}))
+		swiss, _ := QuoteStyleForLocale("de-CH")
+		testx.AssertEqual(t, "", SwissQuotes, swiss)
+		_, ok = QuoteStyleForLocale("xx")
+		testx.AssertFalse(t, "", ok)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		testx.AssertEqual(t, "", "<p>\"a\" -- 'b'... \"c\"</p>\n", convert(`"a" -- 'b'... {"c"}`, ParseOptions{NoSmartPunctuation: true}))
+	})
+}
+
+func TestSectionIdSlug(t *testing.T) {
+	testx.AssertEqual(t, "", "Über-Uns", CreateSectionId("Über Uns"))
+	testx.AssertEqual(t, "", "über-uns--c-api_v2", GitHubSlug("Über Uns & C API_v2!"))
+	testx.AssertEqual(t, "", "uber-uns-strasse-c-api", ASCIISlug("Über Uns: Straße — C++ API"))
+
+	document := []byte("See [Über Uns][].\n\n# Über Uns\n")
+	ast := BuildDjotAstWithOptions(document, ParseOptions{Slug: ASCIISlug})
+	testx.AssertEqual(t, "", `<p>See <a href="#uber-uns">Über Uns</a>.</p>
+<section id="uber-uns">
+<h1>Über Uns</h1>
+</section>
+`, NewConversionContext("html", DefaultConversionRegistry).ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
+}
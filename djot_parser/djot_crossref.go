@@ -0,0 +1,122 @@
+package djot_parser
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/tokenizer"
+)
+
+const (
+	CrossReferenceKey = "$CrossReferenceKey" // label of the numbered element, e.g. "Figure 3" or "Section 2.1"
+	FigureClass       = "figure"
+)
+
+// CrossReferenceLabels are prefixes of the numbers of the elements of each type
+type CrossReferenceLabels struct {
+	Figure  string
+	Table   string
+	Section string
+}
+
+var DefaultCrossReferenceLabels = CrossReferenceLabels{Figure: "Figure", Table: "Table", Section: "Section"}
+
+// BuildDjotAstWithCrossReferences parses document like BuildDjotAst and numbers its figures, tables and sections with
+// NumberCrossReferences; explicit ids of the elements can be used as reference labels too, so [][fig-arch] works
+// like [](#fig-arch). It is the same as ParseOptions.CrossReferences with other options left default.
+func BuildDjotAstWithCrossReferences(document []byte, labels CrossReferenceLabels) []TreeNode[DjotNode] {
+	return BuildDjotAstWithOptions(document, ParseOptions{CrossReferences: &labels})
+}
+
+// addIds makes explicit ids from attributes available as reference labels, like ids of the headings
+func (context DjotContext) addIds(list tokenizer.TokenList[djot_tokenizer.DjotToken]) {
+	for _, token := range list {
+		if token.Type != djot_tokenizer.Attribute {
+			continue
+		}
+		if id, ok := token.Attributes.TryGet(IdKey); ok {
+			// don't overwrite reference if any
+			if _, ok := context.References[id]; !ok {
+				context.References[id] = []byte("#" + id)
+			}
+		}
+	}
+}
+
+// NumberCrossReferences numbers tables with captions, figures, images and divs with figure class and sections in the
+// document order (sections are numbered hierarchically by heading level), stores label with number in
+// CrossReferenceKey attribute and replaces empty text of links to their ids with this label. Links with text and links
+// to unknown ids are left untouched. Nodes are modified in place.
+func NumberCrossReferences(ast []TreeNode[DjotNode], labels CrossReferenceLabels) []TreeNode[DjotNode] {
+	n := crossReferenceNumbering{labels: labels, numbers: make(map[string]string)}
+	n.number(ast)
+	n.resolve(ast)
+	return ast
+}
+
+type crossReferenceNumbering struct {
+	labels          CrossReferenceLabels
+	figures, tables int
+	sections        []int
+	numbers         map[string]string
+}
+
+func hasClass(node TreeNode[DjotNode], class string) bool {
+	return slices.Contains(strings.Fields(node.Attributes.Get(djot_tokenizer.DjotAttributeClassKey)), class)
+}
+
+func (n *crossReferenceNumbering) register(node *TreeNode[DjotNode], label string) {
+	node.Attributes.Set(CrossReferenceKey, label)
+	// the first element wins if id is duplicated
+	if id, ok := node.Attributes.TryGet(IdKey); ok {
+		if _, ok := n.numbers[id]; !ok {
+			n.numbers[id] = label
+		}
+	}
+}
+
+func (n *crossReferenceNumbering) number(nodes []TreeNode[DjotNode]) {
+	for i := range nodes {
+		node := &nodes[i]
+		switch {
+		case node.Type == SectionNode && len(node.Children) > 0 && node.Children[0].Type == HeadingNode:
+			level := len(node.Children[0].Attributes.Get(HeadingLevelKey))
+			for len(n.sections) < level {
+				n.sections = append(n.sections, 0)
+			}
+			n.sections = n.sections[:level]
+			n.sections[level-1]++
+			numbers := make([]string, level)
+			for j, number := range n.sections {
+				numbers[j] = fmt.Sprint(number)
+			}
+			label := n.labels.Section + " " + strings.Join(numbers, ".")
+			n.register(node, label)
+			n.register(&node.Children[0], label)
+		case node.Type == TableNode && len(node.Children) > 0 && node.Children[0].Type == TableCaptionNode:
+			n.tables++
+			n.register(node, fmt.Sprintf("%v %v", n.labels.Table, n.tables))
+		case node.Type == FigureNode, (node.Type == ImageNode || node.Type == DivNode) && hasClass(*node, FigureClass):
+			n.figures++
+			n.register(node, fmt.Sprintf("%v %v", n.labels.Figure, n.figures))
+		}
+		n.number(node.Children)
+	}
+}
+
+func (n *crossReferenceNumbering) resolve(nodes []TreeNode[DjotNode]) {
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Type != LinkNode {
+			n.resolve(node.Children)
+			continue
+		}
+		id, ok := strings.CutPrefix(node.Attributes.Get(LinkHrefKey), "#")
+		if label, known := n.numbers[id]; ok && known && len(bytes.TrimSpace(node.FullText())) == 0 {
+			node.Children = []TreeNode[DjotNode]{{Type: TextNode, Text: []byte(label)}}
+		}
+	}
+}
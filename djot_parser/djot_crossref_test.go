@@ -0,0 +1,69 @@
+package djot_parser
+
+import (
+	"testing"
+
+	"md0.org/djot/html_writer"
+	"md0.org/djot/internal/testx"
+)
+
+func TestBuildDjotAstWithCrossReferences(t *testing.T) {
+	document := []byte(`# Intro
+
+![Architecture](arch.png){#fig-arch .figure}
+
+{#data}
+## Data
+
+{#tbl-sizes}
+| a | b |
+
+^ Sizes
+
+{#fig-flow .figure}
+:::
+Flow
+:::
+
+See [](#fig-flow), [][fig-arch], [](#tbl-sizes), [](#data), [](#Intro), [](#missing) and [text](#fig-arch).
+`)
+	ast := BuildDjotAstWithCrossReferences(document, CrossReferenceLabels{Figure: "Fig.", Table: "Table", Section: "§"})
+	testx.AssertEqual(t, "", `<section id="Intro">
+<h1>Intro</h1>
+<p><img class="figure" id="fig-arch" alt="Architecture" src="arch.png"></p>
+</section>
+<section id="Data">
+<h2 id="data">Data</h2>
+<table id="tbl-sizes">
+<caption>Sizes</caption>
+<tbody><tr>
+<td>a</td>
+<td>b</td>
+</tr>
+</tbody></table>
+<div class="figure" id="fig-flow">
+<p>Flow</p>
+</div>
+<p>See <a href="#fig-flow">Fig. 2</a>, <a href="#fig-arch">Fig. 1</a>, <a href="#tbl-sizes">Table 1</a>, <a href="#data">§ 1.1</a>, <a href="#Intro">§ 1</a>, <a href="#missing"></a> and <a href="#fig-arch">text</a>.</p>
+</section>
+`, NewConversionContext("html", DefaultConversionRegistry).ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
+	testx.AssertEqual(t, "", "Table 1", ast[0].Children[1].Children[1].Attributes.Get(CrossReferenceKey))
+}
+
+func TestCrossReferencesOption(t *testing.T) {
+	labels := DefaultCrossReferenceLabels
+	ast := BuildDjotAstWithOptions([]byte("# Über Uns\n\n![Flow](flow.png){#flow}\n\nSee [](#flow) in [](#uber-uns) and [][flow].\n"), ParseOptions{
+		Slug:            ASCIISlug,
+		Figures:         true,
+		CrossReferences: &labels,
+	})
+	testx.AssertEqual(t, "", `<section id="uber-uns">
+<h1>Über Uns</h1>
+<figure id="flow">
+<img alt="Flow" src="flow.png">
+<figcaption><span class="figure-label">Figure 1:</span> Flow</figcaption>
+</figure>
+<p>See <a href="#flow">Figure 1</a> in <a href="#uber-uns">Section 1</a> and <a href="#flow">Figure 1</a>.</p>
+</section>
+`, NewConversionContext("html", DefaultConversionRegistry).ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
+}
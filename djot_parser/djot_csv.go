@@ -0,0 +1,213 @@
+package djot_parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/tokenizer"
+)
+
+const (
+	CSVFormat    = "csv"    // format of the raw block or class of the div converted into the table
+	TSVFormat    = "tsv"    // same as CSVFormat for tab separated values
+	CSVHeaderKey = "header" // {header=true} or {header=false} overrides header row detection
+	CSVAlignKey  = "align"  // {align="left - right"} sets alignment of the columns, "-" keeps the default one
+)
+
+type (
+	// CSVOptions changes how CSV data is converted into the table
+	CSVOptions struct {
+		Comma      rune      // field separator, ',' if zero
+		Header     CSVHeader // DetectHeader if zero
+		Alignments []string  // LeftAlignment, CenterAlignment, RightAlignment or DefaultAlignment of every column
+	}
+	// CSVHeader selects whether the first row is written as the header
+	CSVHeader int
+)
+
+const (
+	DetectHeader   CSVHeader = iota // first row is the header if it isn't the only row and has no empty or numeric cells
+	FirstRowHeader                  // first row is always the header
+	NoHeader                        // all rows are written as data
+)
+
+// CSVTable converts CSV data into the table with plain text cells: values are trimmed, shorter rows are padded with
+// empty cells and columns get alignment in the same way as the cells of the pipe table
+func CSVTable(data []byte, options CSVOptions) (TreeNode[DjotNode], error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	if options.Comma != 0 {
+		reader.Comma = options.Comma
+	}
+	// leading tabs can't be trimmed in TSV as they are empty fields
+	reader.TrimLeadingSpace = reader.Comma != '\t'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return TreeNode[DjotNode]{}, err
+	}
+	columns := 0
+	for _, record := range records {
+		columns = max(columns, len(record))
+	}
+	header := options.Header == FirstRowHeader || options.Header == DetectHeader && isCSVHeader(records)
+	table := TreeNode[DjotNode]{Type: TableNode, Children: make([]TreeNode[DjotNode], 0, len(records))}
+	for i, record := range records {
+		cellType := TableCellNode
+		if i == 0 && header {
+			cellType = TableHeaderNode
+		}
+		row := TreeNode[DjotNode]{Type: TableRowNode, Children: make([]TreeNode[DjotNode], 0, columns)}
+		for column := 0; column < columns; column++ {
+			cell := TreeNode[DjotNode]{Type: cellType}
+			if column < len(record) {
+				if text := strings.TrimSpace(record[column]); text != "" {
+					cell.Children = []TreeNode[DjotNode]{{Type: TextNode, Text: []byte(text)}}
+				}
+			}
+			if column < len(options.Alignments) && options.Alignments[column] != DefaultAlignment {
+				cell.Attributes.Set(TableAlignmentKey, options.Alignments[column])
+			}
+			row.Children = append(row.Children, cell)
+		}
+		table.Children = append(table.Children, row)
+	}
+	return table, nil
+}
+
+func isCSVHeader(records [][]string) bool {
+	if len(records) < 2 {
+		return false
+	}
+	for _, value := range records[0] {
+		value = strings.TrimSpace(value)
+		if _, err := strconv.ParseFloat(value, 64); value == "" || err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// csvFormat returns format of the raw block or the div which should be converted into the table
+func csvFormat(node DjotNode, attributes tokenizer.Attributes) (string, bool) {
+	var formats []string
+	switch node {
+	case RawNode:
+		formats = []string{attributes.Get(RawBlockFormatKey)}
+	case DivNode:
+		formats = strings.Fields(attributes.Get(djot_tokenizer.DjotAttributeClassKey))
+	}
+	for _, format := range formats {
+		if format == CSVFormat || format == TSVFormat {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// csvBlockTable converts content of ```=csv raw block or ::: csv div into the table, attributes of the block are
+// moved to the table except for the csv options; block is left as is if its content isn't valid CSV
+func csvBlockTable(content []byte, format string, attributes tokenizer.Attributes) (TreeNode[DjotNode], bool) {
+	options := CSVOptions{Alignments: strings.Fields(attributes.Get(CSVAlignKey))}
+	if format == TSVFormat {
+		options.Comma = '\t'
+	}
+	switch attributes.Get(CSVHeaderKey) {
+	case "true":
+		options.Header = FirstRowHeader
+	case "false":
+		options.Header = NoHeader
+	}
+	for i, alignment := range options.Alignments {
+		if !slices.Contains([]string{LeftAlignment, CenterAlignment, RightAlignment}, alignment) {
+			options.Alignments[i] = DefaultAlignment
+		}
+	}
+	table, err := CSVTable(content, options)
+	if err != nil {
+		return TreeNode[DjotNode]{}, false
+	}
+	attributes = withoutClass(attributes, format)
+	for _, entry := range attributes.Entries() {
+		switch entry.Key {
+		case RawBlockFormatKey, CSVHeaderKey, CSVAlignKey:
+		default:
+			table.Attributes.Set(entry.Key, entry.Value)
+		}
+	}
+	return table, true
+}
+
+var djotTableCellReplacer = strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r\n", " ", "\n", " ")
+
+// DjotTable writes rows of the table as djot pipe table: cells are written as plain text with escaped pipes and
+// backslashes, line breaks become spaces and columns are padded to the same width. Separator row with the alignment
+// of the columns follows the leading header rows, or goes first if the table has no header but aligned columns
+func DjotTable(table TreeNode[DjotNode]) []byte {
+	rows := make([][]string, 0, len(table.Children))
+	widths, alignments := make([]int, 0), make([]string, 0)
+	header, aligned := 0, false
+	for _, row := range table.Children {
+		if row.Type != TableRowNode {
+			continue
+		}
+		if header == len(rows) && isTableHeaderRow(row) {
+			header++
+		}
+		cells := make([]string, 0, len(row.Children))
+		for column, cell := range row.Children {
+			text := djotTableCellReplacer.Replace(strings.TrimSpace(string(cell.FullText())))
+			if column == len(widths) {
+				widths, alignments = append(widths, 3), append(alignments, DefaultAlignment)
+			}
+			widths[column] = max(widths[column], utf8.RuneCountInString(text))
+			if alignment := cell.Attributes.Get(TableAlignmentKey); alignment != DefaultAlignment && len(rows) == 0 {
+				alignments[column], aligned = alignment, true
+			}
+			cells = append(cells, text)
+		}
+		rows = append(rows, cells)
+	}
+	var djot bytes.Buffer
+	writeSeparator := func() {
+		for column, width := range widths {
+			separator := []byte("|" + strings.Repeat("-", width+2))
+			switch alignments[column] {
+			case LeftAlignment:
+				separator[1] = ':'
+			case RightAlignment:
+				separator[len(separator)-1] = ':'
+			case CenterAlignment:
+				separator[1], separator[len(separator)-1] = ':', ':'
+			}
+			djot.Write(separator)
+		}
+		djot.WriteString("|\n")
+	}
+	for i, cells := range rows {
+		if i == header && (header > 0 || aligned) {
+			writeSeparator()
+		}
+		for column, width := range widths {
+			text := ""
+			if column < len(cells) {
+				text = cells[column]
+			}
+			padding := strings.Repeat(" ", width-utf8.RuneCountInString(text))
+			if alignments[column] == RightAlignment {
+				djot.WriteString("| " + padding + text + " ")
+			} else {
+				djot.WriteString("| " + text + padding + " ")
+			}
+		}
+		djot.WriteString("|\n")
+	}
+	if header == len(rows) && header > 0 {
+		writeSeparator()
+	}
+	return djot.Bytes()
+}
@@ -0,0 +1,67 @@
+package djot_parser
+
+import (
+	"testing"
+
+	"md0.org/djot/html_writer"
+	"md0.org/djot/internal/testx"
+)
+
+func TestCSV(t *testing.T) {
+	convert := func(ast []TreeNode[DjotNode]) string {
+		return NewConversionContext("html").ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...)
+	}
+	t.Run("raw block", func(t *testing.T) {
+		ast := BuildDjotAstWithOptions([]byte("{#sizes align=\"left - right\"}\n```=csv\nName, Unit, Size\n\"a, b\", cm, 1\nc\n```\n"), ParseOptions{CSV: true})
+		testx.AssertEqual(t, "", `<table id="sizes">
+<tr>
+<th style="text-align: left;">Name</th>
+<th>Unit</th>
+<th style="text-align: right;">Size</th>
+</tr>
+<tr>
+<td style="text-align: left;">a, b</td>
+<td>cm</td>
+<td style="text-align: right;">1</td>
+</tr>
+<tr>
+<td style="text-align: left;">c</td>
+<td></td>
+<td style="text-align: right;"></td>
+</tr>
+</table>
+`, convert(ast))
+	})
+	t.Run("tsv div", func(t *testing.T) {
+		ast := BuildDjotAstWithOptions([]byte("{#data header=true}\n::: tsv\n1\t\t2\n:::\n"), ParseOptions{CSV: true})
+		testx.AssertEqual(t, "", "<table id=\"data\">\n<tr>\n<th>1</th>\n<th></th>\n<th>2</th>\n</tr>\n</table>\n", convert(ast))
+	})
+	t.Run("numeric header", func(t *testing.T) {
+		table, err := CSVTable([]byte("1,2\n3,4\n"), CSVOptions{})
+		testx.AssertNilError(t, "", err)
+		testx.AssertFalse(t, "", isTableHeaderRow(table.Children[0]))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		ast := BuildDjotAstWithOptions([]byte("```=csv\na,\"b\n```\n"), ParseOptions{CSV: true})
+		testx.AssertEqual(t, "", RawNode, ast[0].Children[0].Type)
+		_, err := CSVTable([]byte("a,\"b\n"), CSVOptions{})
+		testx.AssertNotNil(t, "", err)
+	})
+	t.Run("djot", func(t *testing.T) {
+		table, err := CSVTable([]byte("Pattern,Size\na|b,10\n"), CSVOptions{Alignments: []string{CenterAlignment, RightAlignment}})
+		testx.AssertNilError(t, "", err)
+		djot := DjotTable(table)
+		testx.AssertEqual(t, "", "| Pattern | Size |\n|:-------:|-----:|\n| a\\|b    |   10 |\n", string(djot))
+		testx.AssertEqual(t, "", `<table>
+<tr>
+<th style="text-align: center;">Pattern</th>
+<th style="text-align: right;">Size</th>
+</tr>
+<tr>
+<td style="text-align: center;">a|b</td>
+<td style="text-align: right;">10</td>
+</tr>
+</table>
+`, convert(BuildDjotAst(djot)))
+	})
+}
@@ -0,0 +1,217 @@
+package djot_parser
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/tokenizer"
+)
+
+type Severity int
+
+const (
+	ErrorSeverity Severity = iota
+	WarningSeverity
+)
+
+func (s Severity) String() string {
+	switch s {
+	case ErrorSeverity:
+		return "error"
+	case WarningSeverity:
+		return "warning"
+	default:
+		panic(fmt.Errorf("unexpected severity: %d", int(s)))
+	}
+}
+
+const (
+	UndefinedReferenceRule = "undefined-reference"
+	UnusedReferenceRule    = "unused-reference"
+	DuplicateReferenceRule = "duplicate-reference"
+	UndefinedFootnoteRule  = "undefined-footnote"
+	UnusedFootnoteRule     = "unused-footnote"
+	DuplicateFootnoteRule  = "duplicate-footnote"
+	DuplicateIdRule        = "duplicate-id"
+	UnclosedVerbatimRule   = "unclosed-verbatim"
+)
+
+type Diagnostic struct {
+	Severity Severity
+	Rule     string
+	Message  string
+	Range    tokenizer.Range // byte range in the document
+	File     string          // name of the file the range points into, empty if document wasn't read from the file system
+}
+
+// DocumentPosition converts byte offset to 1-based line and column (in runes)
+func DocumentPosition(document []byte, offset int) (line, column int) {
+	line, lineStart := 1, 0
+	for i := 0; i < offset && i < len(document); i++ {
+		if document[i] == '\n' {
+			line, lineStart = line+1, i+1
+		}
+	}
+	return line, utf8.RuneCount(document[lineStart:min(offset, len(document))]) + 1
+}
+
+func BuildDjotAstWithDiagnostics(document []byte) ([]TreeNode[DjotNode], []Diagnostic) {
+	return BuildDjotAstWithDiagnosticsAndOptions(document, ParseOptions{})
+}
+
+// BuildDjotAstWithDiagnosticsAndOptions returns the same AST as BuildDjotAstWithOptions and diagnostics of the
+// document, ids of the headings are checked with the configured slug
+func BuildDjotAstWithDiagnosticsAndOptions(document []byte, options ParseOptions) ([]TreeNode[DjotNode], []Diagnostic) {
+	tokens := djot_tokenizer.BuildDjotTokens(document)
+	context := BuildDjotContextWithOptions(document, tokens, options)
+	ast := options.transform(linkFootnotes(buildDjotAst(document, context, DjotLocalContext{}, tokens)))
+	return ast, BuildDjotDiagnostics(document, context, tokens)
+}
+
+type (
+	definition struct {
+		label string
+		file  string
+		rng   tokenizer.Range
+	}
+	// diagnosticsBuilder collects diagnostics of the documents sharing single context, so definitions and usages
+	// are matched across documents
+	diagnosticsBuilder struct {
+		context                             DjotContext
+		diagnostics                         []Diagnostic
+		files                               map[string]int
+		references, footnotes               []definition
+		usedReferences, usedFootnotes       map[string]bool
+		definedReferences, definedFootnotes map[string]bool
+		ids                                 map[string]bool
+	}
+)
+
+// BuildDjotDiagnostics reports constructs which parser silently resolves: undefined, unused or duplicate references
+// and footnotes, duplicate ids and auto-closed verbatim
+func BuildDjotDiagnostics(document []byte, context DjotContext, list tokenizer.TokenList[djot_tokenizer.DjotToken]) []Diagnostic {
+	b := newDiagnosticsBuilder(context)
+	b.add("", document, list)
+	return b.build()
+}
+
+func newDiagnosticsBuilder(context DjotContext) *diagnosticsBuilder {
+	return &diagnosticsBuilder{
+		context:           context,
+		diagnostics:       make([]Diagnostic, 0),
+		files:             make(map[string]int),
+		references:        make([]definition, 0),
+		footnotes:         make([]definition, 0),
+		usedReferences:    make(map[string]bool),
+		usedFootnotes:     make(map[string]bool),
+		definedReferences: make(map[string]bool),
+		definedFootnotes:  make(map[string]bool),
+		ids:               make(map[string]bool),
+	}
+}
+
+func (b *diagnosticsBuilder) report(file string, severity Severity, rule string, rng tokenizer.Range, format string, args ...any) {
+	b.diagnostics = append(b.diagnostics, Diagnostic{Severity: severity, Rule: rule, Message: fmt.Sprintf(format, args...), Range: rng, File: file})
+}
+
+func (b *diagnosticsBuilder) add(file string, document []byte, list tokenizer.TokenList[djot_tokenizer.DjotToken]) {
+	if _, ok := b.files[file]; !ok {
+		b.files[file] = len(b.files)
+	}
+	report := func(severity Severity, rule string, rng tokenizer.Range, format string, args ...any) {
+		b.report(file, severity, rule, rng, format, args...)
+	}
+	defineId := func(id string, rng tokenizer.Range) {
+		if b.ids[id] {
+			report(WarningSeverity, DuplicateIdRule, rng, "duplicate id '%v'", id)
+		}
+		b.ids[id] = true
+	}
+	for i, token := range list {
+		if token.Type == djot_tokenizer.Attribute {
+			if id, ok := token.Attributes.TryGet(djot_tokenizer.DjotAttributeIdKey); ok {
+				defineId(id, tokenizer.Range{Start: token.Start, End: token.End})
+			}
+			continue
+		}
+		if token.JumpToPair <= 0 {
+			continue
+		}
+		closeToken := list[i+token.JumpToPair]
+		rng := tokenizer.Range{Start: token.Start, End: closeToken.End}
+		switch token.Type {
+		case djot_tokenizer.ReferenceDefBlock:
+			reference := token.Attributes.Get(djot_tokenizer.ReferenceKey)
+			if b.definedReferences[reference] {
+				report(WarningSeverity, DuplicateReferenceRule, rng, "duplicate definition of reference '%v'", reference)
+			}
+			b.definedReferences[reference] = true
+			b.references = append(b.references, definition{label: reference, file: file, rng: rng})
+		case djot_tokenizer.FootnoteDefBlock:
+			reference := token.Attributes.Get(djot_tokenizer.ReferenceKey)
+			if b.definedFootnotes[reference] {
+				report(WarningSeverity, DuplicateFootnoteRule, rng, "duplicate definition of footnote '%v'", reference)
+			}
+			b.definedFootnotes[reference] = true
+			b.footnotes = append(b.footnotes, definition{label: reference, file: file, rng: rng})
+		case djot_tokenizer.HeadingBlock:
+			id, ok := b.context.SectionIds[token.Start]
+			if !ok {
+				id = b.context.Options.SectionId(string(SelectText(document, list[i+1:i+token.JumpToPair])))
+			}
+			defineId(id, rng)
+		case djot_tokenizer.FootnoteReferenceInline:
+			reference := string(document[token.End:closeToken.Start])
+			b.usedFootnotes[reference] = true
+			if _, ok := b.context.FootnoteId[reference]; !ok {
+				report(ErrorSeverity, UndefinedFootnoteRule, rng, "undefined footnote '%v'", reference)
+			}
+		case djot_tokenizer.LinkReferenceInline:
+			spanClose := i - 1
+			if spanClose < 0 || list[spanClose].JumpToPair >= 0 {
+				continue
+			}
+			spanOpen := spanClose + list[spanClose].JumpToPair
+			if list[spanOpen].Type != djot_tokenizer.SpanInline && list[spanOpen].Type != djot_tokenizer.ImageSpanInline {
+				continue
+			}
+			reference := normalizeLinkText(document[token.End:closeToken.Start])
+			if len(reference) == 0 {
+				reference = SelectText(document, list[spanOpen+1:spanClose])
+			}
+			b.usedReferences[string(reference)] = true
+			if len(normalizeLinkText(b.context.References[string(reference)])) == 0 {
+				report(ErrorSeverity, UndefinedReferenceRule, tokenizer.Range{Start: list[spanOpen].Start, End: closeToken.End}, "undefined reference '%s'", reference)
+			}
+		case djot_tokenizer.VerbatimInline:
+			if closeToken.Length() == 0 {
+				report(WarningSeverity, UnclosedVerbatimRule, rng, "verbatim is not closed")
+			}
+		}
+	}
+}
+
+// build reports unused definitions and returns all diagnostics ordered by file and position
+func (b *diagnosticsBuilder) build() []Diagnostic {
+	for _, reference := range b.references {
+		if !b.usedReferences[reference.label] {
+			b.usedReferences[reference.label] = true // report duplicated definitions only once
+			b.report(reference.file, WarningSeverity, UnusedReferenceRule, reference.rng, "reference '%v' is never used", reference.label)
+		}
+	}
+	for _, footnote := range b.footnotes {
+		if !b.usedFootnotes[footnote.label] {
+			b.usedFootnotes[footnote.label] = true
+			b.report(footnote.file, WarningSeverity, UnusedFootnoteRule, footnote.rng, "footnote '%v' is never used", footnote.label)
+		}
+	}
+	sort.SliceStable(b.diagnostics, func(i, j int) bool {
+		if b.files[b.diagnostics[i].File] != b.files[b.diagnostics[j].File] {
+			return b.files[b.diagnostics[i].File] < b.files[b.diagnostics[j].File]
+		}
+		return b.diagnostics[i].Range.Start < b.diagnostics[j].Range.Start
+	})
+	return b.diagnostics
+}
@@ -0,0 +1,53 @@
+package djot_parser
+
+import (
+	"fmt"
+	"testing"
+
+	"md0.org/djot/internal/testx"
+)
+
+func printDiagnostics(text string) []string {
+	document := []byte(text)
+	_, diagnostics := BuildDjotAstWithDiagnostics(document)
+	result := make([]string, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		line, column := DocumentPosition(document, diagnostic.Range.Start)
+		result = append(result, fmt.Sprintf("%v:%v: %v: %v: %v", line, column, diagnostic.Severity, diagnostic.Rule, diagnostic.Message))
+	}
+	return result
+}
+
+func TestDiagnostics(t *testing.T) {
+	t.Run("references", func(t *testing.T) {
+		testx.AssertEqual(t, "", []string{
+			"1:1: error: undefined-reference: undefined reference 'missing'",
+			"3:1: warning: unused-reference: reference 'c' is never used",
+			"4:1: warning: duplicate-reference: duplicate definition of reference 'c'",
+		}, printDiagnostics("[a][missing] [b][]\n\n[c]: /c\n[c]: /d\n[b]: /b\n"))
+	})
+	t.Run("footnotes", func(t *testing.T) {
+		testx.AssertEqual(t, "", []string{
+			"1:5: error: undefined-footnote: undefined footnote 'x'",
+			"3:1: warning: unused-footnote: footnote 'y' is never used",
+		}, printDiagnostics("see [^x]\n\n[^y]: note\n"))
+	})
+	t.Run("duplicate ids and verbatim", func(t *testing.T) {
+		testx.AssertEqual(t, "", []string{
+			"3:1: warning: duplicate-id: duplicate id 'Title'",
+			"6:1: warning: duplicate-id: duplicate id 'Title'",
+			"7:8: warning: unclosed-verbatim: verbatim is not closed",
+		}, printDiagnostics("# Title\n\n# Title\n\n{#other}\n{#Title}\ntext é `code\n"))
+	})
+	t.Run("options", func(t *testing.T) {
+		document := []byte("# Über Uns\n\n{#uber-uns}\nhttps://example.com\n")
+		ast, diagnostics := BuildDjotAstWithDiagnosticsAndOptions(document, ParseOptions{Slug: ASCIISlug, Linkify: true})
+		testx.AssertEqual(t, "", 1, len(diagnostics))
+		testx.AssertEqual(t, "", "duplicate id 'uber-uns'", diagnostics[0].Message)
+		testx.AssertEqual(t, "", BuildDjotAstWithOptions(document, ParseOptions{Slug: ASCIISlug, Linkify: true})[0].String(), ast[0].String())
+		testx.AssertEqual(t, "", LinkNode, ast[0].Children[0].Children[1].Children[0].Type)
+	})
+	t.Run("clean document", func(t *testing.T) {
+		testx.AssertEqual(t, "", []string{}, printDiagnostics("# Title\n\nSee [title][Title] and [^n].\n\n[^n]: note\n"))
+	})
+}
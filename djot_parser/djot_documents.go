@@ -0,0 +1,94 @@
+package djot_parser
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/tokenizer"
+)
+
+// BuildJoinedDjotAst parses documents as consecutive parts of the single document (chapters of the book): references
+// and footnotes are shared, footnotes are numbered through all documents by the first reference and collected at the end, and section ids
+// which were already used by previous documents get numeric suffix (implicit references to such heading from its own
+// document lead to the renamed section)
+func BuildJoinedDjotAst(documents ...[]byte) []TreeNode[DjotNode] {
+	return BuildJoinedDjotAstWithOptions(ParseOptions{}, documents...)
+}
+
+// BuildJoinedDjotAstWithOptions is BuildJoinedDjotAst with options applied to all documents
+func BuildJoinedDjotAstWithOptions(options ParseOptions, documents ...[]byte) []TreeNode[DjotNode] {
+	context := newDjotContext()
+	context.Options = options
+	lists := make([]tokenizer.TokenList[djot_tokenizer.DjotToken], len(documents))
+	for i, document := range documents {
+		lists[i] = djot_tokenizer.BuildDjotTokens(document)
+		context.add(document, lists[i])
+	}
+	context.numberFootnotes()
+
+	used := make(map[string]bool)
+	children, footnotes := make([]TreeNode[DjotNode], 0), make([]TreeNode[DjotNode], 0)
+	for i, document := range documents {
+		documentContext := uniqueSectionIds(context, used, documents[i:i+1], lists[i:i+1])[0]
+		for _, node := range buildDjotAst(document, documentContext, DjotLocalContext{}, lists[i]) {
+			nodes := node.Children
+			if last := len(nodes) - 1; last >= 0 && nodes[last].Type == SectionNode && nodes[last].Attributes.Get(RoleKey) == EndnotesRole {
+				footnotes = append(footnotes, nodes[last].Children[1].Children...)
+				nodes = nodes[:last]
+			}
+			children = append(children, nodes...)
+		}
+	}
+	if len(footnotes) > 0 {
+		children = append(children, endnotesSection(footnotes))
+	}
+	return options.transform(linkFootnotes([]TreeNode[DjotNode]{{Type: DocumentNode, Children: children}}))
+}
+
+// uniqueSectionIds gives headings of the documents ids which weren't used by the previous documents and returns
+// context for each of them. Documents are parts of the same chapter (file with its includes): duplicates inside of
+// the chapter are kept as is, like in the standalone document.
+func uniqueSectionIds(context DjotContext, used map[string]bool, documents [][]byte, lists []tokenizer.TokenList[djot_tokenizer.DjotToken]) []DjotContext {
+	renamed, references, cloned := make(map[string]string), context.References, false
+	contexts := make([]DjotContext, len(documents))
+	for i, document := range documents {
+		list := lists[i]
+		contexts[i] = context
+		contexts[i].SectionIds = make(map[int]string)
+		for j, token := range list {
+			if token.Type != djot_tokenizer.HeadingBlock {
+				continue
+			}
+			text := string(SelectText(document, list[j+1:j+token.JumpToPair]))
+			id := context.Options.SectionId(text)
+			unique, ok := renamed[id]
+			if !ok {
+				unique = id
+				for suffix := 1; used[unique]; suffix++ {
+					unique = fmt.Sprintf("%v-%v", id, suffix)
+				}
+				renamed[id] = unique
+				used[unique] = true
+			}
+			contexts[i].SectionIds[token.Start] = unique
+			if unique == id {
+				continue
+			}
+			// implicit references to the renamed heading lead to the heading of this chapter
+			if !cloned {
+				references, cloned = maps.Clone(context.References), true
+			}
+			for _, label := range []string{strings.TrimSpace(text), id} {
+				if string(context.References[label]) == "#"+id {
+					references[label] = []byte("#" + unique)
+				}
+			}
+		}
+	}
+	for i := range contexts {
+		contexts[i].References = references
+	}
+	return contexts
+}
@@ -0,0 +1,54 @@
+package djot_parser
+
+import (
+	"testing"
+
+	"md0.org/djot/html_writer"
+	"md0.org/djot/internal/testx"
+)
+
+func TestBuildJoinedDjotAst(t *testing.T) {
+	ast := BuildJoinedDjotAst(
+		[]byte("# Intro\n\nSee [site][] and note[^a].\n\n[^a]: First.\n"),
+		[]byte("# Intro\n\n## Usage\n\nOther[^b] [Intro][].\n\n[site]: https://example.com\n\n[^b]: Second.\n"),
+	)
+	testx.AssertEqual(t, "", `<section id="Intro">
+<h1>Intro</h1>
+<p>See <a href="https://example.com">site</a> and note<a id="fnref1" href="#fn1" role="doc-noteref"><sup>1</sup></a>.</p>
+</section>
+<section id="Intro-1">
+<h1>Intro</h1>
+</section>
+<section id="Usage">
+<h2>Usage</h2>
+<p>Other<a id="fnref2" href="#fn2" role="doc-noteref"><sup>2</sup></a> <a href="#Intro-1">Intro</a>.</p>
+</section>
+<section role="doc-endnotes">
+<hr>
+<ol>
+<li id="fn1">
+<p>First.<a href="#fnref1" role="doc-backlink">↩︎︎</a></p>
+</li>
+<li id="fn2">
+<p>Second.<a href="#fnref2" role="doc-backlink">↩︎︎</a></p>
+</li>
+</ol>
+</section>
+`, NewConversionContext("html", DefaultConversionRegistry).ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
+
+	t.Run("generated id collides with heading", func(t *testing.T) {
+		ast := BuildJoinedDjotAst([]byte("# Intro\n"), []byte("# Intro\n\n# Intro 1\n\n[Intro 1][]\n"), []byte("[Intro][]\n"))
+		testx.AssertEqual(t, "", `<section id="Intro">
+<h1>Intro</h1>
+</section>
+<section id="Intro-1">
+<h1>Intro</h1>
+</section>
+<section id="Intro-1-1">
+<h1>Intro 1</h1>
+<p><a href="#Intro-1-1">Intro 1</a></p>
+</section>
+<p><a href="#Intro">Intro</a></p>
+`, NewConversionContext("html", DefaultConversionRegistry).ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
+	})
+}
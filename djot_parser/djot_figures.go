@@ -0,0 +1,134 @@
+package djot_parser
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/html_writer"
+	"md0.org/djot/tokenizer"
+)
+
+const FigureLabelClass = "figure-label"
+
+// Figures turns images alone in their paragraphs and divs with figure class into figures. Caption of the image figure
+// is its alt text, caption of the div figure is its last paragraph unless it is an image. Id and cross-reference label
+// of the image are moved to the figure, so NumberCrossReferences called after Figures numbers every figure once and
+// the label is written before the caption. Nodes are modified in place.
+func Figures(ast []TreeNode[DjotNode]) []TreeNode[DjotNode] {
+	for i := range ast {
+		node := &ast[i]
+		if image, ok := onlyImage(*node); ok {
+			*node = imageFigure(*node, image)
+		} else if node.Type == DivNode && hasClass(*node, FigureClass) {
+			*node = divFigure(*node)
+		} else {
+			Figures(node.Children)
+		}
+	}
+	return ast
+}
+
+// onlyImage returns image if it is the only content of the paragraph
+func onlyImage(paragraph TreeNode[DjotNode]) (TreeNode[DjotNode], bool) {
+	if paragraph.Type != ParagraphNode {
+		return TreeNode[DjotNode]{}, false
+	}
+	image, found := TreeNode[DjotNode]{}, false
+	for _, child := range paragraph.Children {
+		switch {
+		case child.Type == TextNode && len(bytes.TrimSpace(child.Text)) == 0:
+		case child.Type == ImageNode && !found:
+			image, found = child, true
+		default:
+			return TreeNode[DjotNode]{}, false
+		}
+	}
+	return image, found
+}
+
+// withoutClass returns copy of the attributes without the class which only marks element for the transformation, like
+// figure class
+func withoutClass(attributes tokenizer.Attributes, marker string) tokenizer.Attributes {
+	result := tokenizer.Attributes{}
+	for _, entry := range attributes.Entries() {
+		if entry.Key == djot_tokenizer.DjotAttributeClassKey {
+			classes := slices.DeleteFunc(strings.Fields(entry.Value), func(class string) bool { return class == marker })
+			if len(classes) == 0 {
+				continue
+			}
+			entry.Value = strings.Join(classes, " ")
+		}
+		result.Set(entry.Key, entry.Value)
+	}
+	return result
+}
+
+func imageFigure(paragraph, image TreeNode[DjotNode]) TreeNode[DjotNode] {
+	attributes, imageAttributes := tokenizer.Attributes{}, withoutClass(image.Attributes, FigureClass)
+	attributes.MergeWith(paragraph.Attributes)
+	image.Attributes = tokenizer.Attributes{}
+	for _, entry := range imageAttributes.Entries() {
+		if entry.Key == IdKey || entry.Key == CrossReferenceKey {
+			attributes.Set(entry.Key, entry.Value)
+		} else {
+			image.Attributes.Set(entry.Key, entry.Value)
+		}
+	}
+	children := []TreeNode[DjotNode]{image}
+	if len(image.Children) > 0 {
+		children = append(children, TreeNode[DjotNode]{Type: FigureCaptionNode, Children: image.Children})
+	}
+	return TreeNode[DjotNode]{Type: FigureNode, Attributes: attributes, Children: children}
+}
+
+func divFigure(div TreeNode[DjotNode]) TreeNode[DjotNode] {
+	body, caption := div.Children, []TreeNode[DjotNode](nil)
+	if last := len(body) - 1; last > 0 && body[last].Type == ParagraphNode {
+		if _, ok := onlyImage(body[last]); !ok {
+			body, caption = body[:last], body[last].Children
+		}
+	}
+	children := make([]TreeNode[DjotNode], 0, len(body)+1)
+	for _, child := range body {
+		if image, ok := onlyImage(child); ok {
+			image.Attributes = withoutClass(image.Attributes, FigureClass)
+			child = image
+		}
+		children = append(children, child)
+	}
+	if len(caption) > 0 {
+		children = append(children, TreeNode[DjotNode]{Type: FigureCaptionNode, Children: caption})
+	}
+	return TreeNode[DjotNode]{Type: FigureNode, Attributes: withoutClass(div.Attributes, FigureClass), Children: children}
+}
+
+// FigureConverter writes <figure> with images on their own lines
+func (state ConversionState) FigureConverter(next func(c Children)) *html_writer.HtmlWriter {
+	return state.BlockNodeConverter("figure", func(Children) {
+		for _, child := range state.Node.Children {
+			next(Children{child})
+			if child.Type == ImageNode {
+				state.Writer.WriteString("\n")
+			}
+		}
+	})
+}
+
+// FigureCaptionConverter writes <figcaption> starting with the cross-reference label of the figure if it is numbered
+func (state ConversionState) FigureCaptionConverter(next func(c Children)) *html_writer.HtmlWriter {
+	return state.Writer.InTag("figcaption", state.Node.Attributes.Entries()...)(func() {
+		label := ""
+		if state.Parent != nil {
+			label = state.Parent.Attributes.Get(CrossReferenceKey)
+		}
+		if label != "" {
+			state.Writer.InTag("span", tokenizer.AttributeEntry{Key: djot_tokenizer.DjotAttributeClassKey, Value: FigureLabelClass})(func() {
+				state.Writer.WriteString(htmlReplacer.Replace(label + ":"))
+			})
+			state.Writer.WriteString(" ")
+		}
+		next(nil)
+	}).WriteString("\n")
+}
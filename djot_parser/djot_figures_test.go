@@ -0,0 +1,51 @@
+package djot_parser
+
+import (
+	"testing"
+
+	"md0.org/djot/html_writer"
+	"md0.org/djot/internal/testx"
+)
+
+func TestFigures(t *testing.T) {
+	convert := func(ast []TreeNode[DjotNode]) string {
+		return NewConversionContext("html").ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...)
+	}
+	t.Run("image", func(t *testing.T) {
+		ast := BuildDjotAstWithOptions([]byte("![The _big_ picture](a.png){#fig-a .figure .wide}\n\nText ![inline](b.png)\n"), ParseOptions{Figures: true})
+		testx.AssertEqual(t, "", `<figure id="fig-a">
+<img class="wide" alt="The big picture" src="a.png">
+<figcaption>The <em>big</em> picture</figcaption>
+</figure>
+<p>Text <img alt="inline" src="b.png"></p>
+`, convert(ast))
+	})
+	t.Run("div", func(t *testing.T) {
+		ast := BuildDjotAstWithOptions([]byte("{#fig-b}\n::: figure\n![Flow](flow.svg)\n\nData *flow*, see [docs](https://example.com)\n:::\n"), ParseOptions{Figures: true})
+		testx.AssertEqual(t, "", `<figure id="fig-b">
+<img alt="Flow" src="flow.svg">
+<figcaption>Data <strong>flow</strong>, see <a href="https://example.com">docs</a></figcaption>
+</figure>
+`, convert(ast))
+	})
+	t.Run("numbered", func(t *testing.T) {
+		ast := NumberCrossReferences(BuildDjotAstWithOptions([]byte("![One](1.png)\n\n![](2.png){#two}\n\n![Three](3.png)\n\nSee [](#two).\n"), ParseOptions{Figures: true}), DefaultCrossReferenceLabels)
+		testx.AssertEqual(t, "", `<figure>
+<img alt="One" src="1.png">
+<figcaption><span class="figure-label">Figure 1:</span> One</figcaption>
+</figure>
+<figure id="two">
+<img alt="" src="2.png">
+</figure>
+<figure>
+<img alt="Three" src="3.png">
+<figcaption><span class="figure-label">Figure 3:</span> Three</figcaption>
+</figure>
+<p>See <a href="#two">Figure 2</a>.</p>
+`, convert(ast))
+		testx.AssertEqual(t, "", "[image: One] <1.png>\nFigure 1: One\n", ConvertDjotToTerm(TermOptions{NoColor: true}, ast[0].Children[0]))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		testx.AssertEqual(t, "", "<p><img alt=\"a\" src=\"a.png\"></p>\n", convert(BuildDjotAst([]byte("![a](a.png)"))))
+	})
+}
@@ -0,0 +1,174 @@
+package djot_parser
+
+import (
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/html_writer"
+	"md0.org/djot/tokenizer"
+)
+
+const SidenoteClass = "sidenote"
+
+type FootnotePlacement int
+
+const (
+	EndnotesPlacement FootnotePlacement = iota // single section at the end of the document
+	SectionPlacement                           // at the end of the top-level section which references the footnote first
+	SidenotePlacement                          // inline sidenote right after the first reference
+	SeparatePlacement                          // footnotes are omitted, ConversionContext.Footnotes writes them
+)
+
+// Footnotes writes footnotes of the nodes as the endnotes section, so caller can place them anywhere when
+// SeparatePlacement is used
+func (context ConversionContext) Footnotes(builder *html_writer.HtmlWriter, nodes ...TreeNode[DjotNode]) string {
+	if _, footnotes := splitFootnotes(nodes); len(footnotes) > 0 {
+		context.convertDjotToHtml(builder, nil, endnotesSection(footnotes))
+	}
+	return builder.String()
+}
+
+func placeFootnotes(placement FootnotePlacement, nodes []TreeNode[DjotNode]) []TreeNode[DjotNode] {
+	if placement == EndnotesPlacement {
+		return nodes
+	}
+	content, footnotes := splitFootnotes(nodes)
+	if len(footnotes) == 0 || placement == SeparatePlacement {
+		return content
+	}
+	placer := footnotePlacer{footnotes: make(map[string]TreeNode[DjotNode]), placed: make(map[string]bool)}
+	for _, footnote := range footnotes {
+		placer.footnotes["#"+footnote.Attributes.Get(IdKey)] = footnote
+	}
+	// footnotes referenced first outside of sections or never referenced from placed content are left at the end
+	rest := make([]TreeNode[DjotNode], 0)
+	if placement == SectionPlacement {
+		rest = placer.sections(topLevel(content))
+	} else {
+		content = placer.sidenotes(content)
+	}
+	for _, footnote := range footnotes {
+		if !placer.placed["#"+footnote.Attributes.Get(IdKey)] {
+			rest = append(rest, footnote)
+		}
+	}
+	if len(rest) > 0 {
+		if document := topLevel(content); document != nil {
+			*document = append(*document, endnotesSection(rest))
+		} else {
+			content = append(content, endnotesSection(rest))
+		}
+	}
+	return content
+}
+
+// splitFootnotes returns copy of the nodes without endnotes sections and footnotes from these sections
+func splitFootnotes(nodes []TreeNode[DjotNode]) ([]TreeNode[DjotNode], []TreeNode[DjotNode]) {
+	content, footnotes := make([]TreeNode[DjotNode], 0, len(nodes)), make([]TreeNode[DjotNode], 0)
+	for _, node := range nodes {
+		if node.Type == SectionNode && node.Attributes.Get(RoleKey) == EndnotesRole {
+			footnotes = append(footnotes, node.Children[1].Children...)
+			continue
+		}
+		var nested []TreeNode[DjotNode]
+		node.Children, nested = splitFootnotes(node.Children)
+		footnotes = append(footnotes, nested...)
+		content = append(content, node)
+	}
+	return content, footnotes
+}
+
+// topLevel returns children of the document node, which contain top-level sections
+func topLevel(nodes []TreeNode[DjotNode]) *[]TreeNode[DjotNode] {
+	if len(nodes) == 1 && nodes[0].Type == DocumentNode {
+		return &nodes[0].Children
+	}
+	return nil
+}
+
+func isFootnoteReference(node TreeNode[DjotNode]) bool {
+	return node.Type == LinkNode && node.Attributes.Get(RoleKey) == "doc-noteref"
+}
+
+type footnotePlacer struct {
+	footnotes map[string]TreeNode[DjotNode] // by href of the reference
+	placed    map[string]bool
+}
+
+// collect returns footnotes first referenced in the nodes, including footnotes referenced from these footnotes
+func (p *footnotePlacer) collect(nodes []TreeNode[DjotNode]) []TreeNode[DjotNode] {
+	collected := make([]TreeNode[DjotNode], 0)
+	for _, node := range nodes {
+		href := node.Attributes.Get(LinkHrefKey)
+		if footnote, ok := p.footnotes[href]; ok && isFootnoteReference(node) && !p.placed[href] {
+			p.placed[href] = true
+			collected = append(collected, footnote)
+			collected = append(collected, p.collect(footnote.Children)...)
+		}
+		collected = append(collected, p.collect(node.Children)...)
+	}
+	return collected
+}
+
+// sections appends footnotes to the top-level sections and returns footnotes referenced first outside of them
+func (p *footnotePlacer) sections(nodes *[]TreeNode[DjotNode]) []TreeNode[DjotNode] {
+	outside := make([]TreeNode[DjotNode], 0)
+	if nodes == nil {
+		return outside
+	}
+	for i, node := range *nodes {
+		footnotes := p.collect([]TreeNode[DjotNode]{node})
+		if node.Type != SectionNode {
+			outside = append(outside, footnotes...)
+		} else if len(footnotes) > 0 {
+			(*nodes)[i].Children = append(node.Children, endnotesSection(footnotes))
+		}
+	}
+	return outside
+}
+
+func (p *footnotePlacer) sidenotes(nodes []TreeNode[DjotNode]) []TreeNode[DjotNode] {
+	result := make([]TreeNode[DjotNode], 0, len(nodes))
+	for _, node := range nodes {
+		node.Children = p.sidenotes(node.Children)
+		result = append(result, node)
+		href := node.Attributes.Get(LinkHrefKey)
+		if footnote, ok := p.footnotes[href]; ok && isFootnoteReference(node) && !p.placed[href] {
+			p.placed[href] = true
+			result = append(result, p.sidenote(footnote))
+		}
+	}
+	return result
+}
+
+// sidenote converts footnote into the span: sidenote is placed inside of the paragraph, so paragraphs are joined
+// with line breaks, other blocks are reduced to their text and backlinks are dropped
+func (p *footnotePlacer) sidenote(footnote TreeNode[DjotNode]) TreeNode[DjotNode] {
+	children := make([]TreeNode[DjotNode], 0)
+	for _, definition := range footnote.Children {
+		for _, block := range definition.Children {
+			if len(children) > 0 {
+				children = append(children, TreeNode[DjotNode]{Type: LineBreakNode})
+			}
+			if block.Type != ParagraphNode {
+				children = append(children, TreeNode[DjotNode]{Type: TextNode, Text: block.FullText()})
+				continue
+			}
+			for _, inline := range block.Children {
+				if inline.Type != LinkNode || inline.Attributes.Get(RoleKey) != "doc-backlink" {
+					children = append(children, inline)
+				}
+			}
+		}
+	}
+	if last := len(children) - 1; last >= 0 && children[last].Type == LineBreakNode {
+		children = children[:last]
+	}
+	return TreeNode[DjotNode]{
+		Type: SpanNode,
+		Attributes: tokenizer.NewAttributes(
+			tokenizer.AttributeEntry{Key: djot_tokenizer.DjotAttributeClassKey, Value: SidenoteClass},
+			tokenizer.AttributeEntry{Key: IdKey, Value: footnote.Attributes.Get(IdKey)},
+			tokenizer.AttributeEntry{Key: RoleKey, Value: "doc-footnote"},
+		),
+		Children: p.sidenotes(children),
+	}
+}
@@ -0,0 +1,107 @@
+package djot_parser
+
+import (
+	"testing"
+
+	"md0.org/djot/html_writer"
+	"md0.org/djot/internal/testx"
+)
+
+func TestFootnotePlacement(t *testing.T) {
+	document := []byte(`Intro[^a].
+
+# One
+
+First[^b] and again[^a].
+
+# Two
+
+Second[^c].
+
+[^a]: Note a.
+
+[^b]: Note b[^d].
+
+[^c]: Note c.
+
+  > quoted
+
+[^d]: Note d.
+`)
+	convert := func(placement FootnotePlacement) string {
+		context := NewConversionContext("html", DefaultConversionRegistry)
+		context.Options.Footnotes = placement
+		return context.ConvertDjotToHtml(&html_writer.HtmlWriter{}, BuildDjotAst(document)...)
+	}
+	t.Run("sections", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<p>Intro<a id="fnref1" href="#fn1" role="doc-noteref"><sup>1</sup></a>.</p>
+<section id="One">
+<h1>One</h1>
+<p>First<a id="fnref2" href="#fn2" role="doc-noteref"><sup>2</sup></a> and again<a id="fnref1-2" href="#fn1" role="doc-noteref"><sup>1</sup></a>.</p>
+<section role="doc-endnotes">
+<hr>
+<ol start="2">
+<li id="fn2">
+<p>Note b<a id="fnref4" href="#fn4" role="doc-noteref"><sup>4</sup></a>.<a href="#fnref2" role="doc-backlink">↩︎︎</a></p>
+</li>
+<li id="fn4" value="4">
+<p>Note d.<a href="#fnref4" role="doc-backlink">↩︎︎</a></p>
+</li>
+</ol>
+</section>
+</section>
+<section id="Two">
+<h1>Two</h1>
+<p>Second<a id="fnref3" href="#fn3" role="doc-noteref"><sup>3</sup></a>.</p>
+<section role="doc-endnotes">
+<hr>
+<ol start="3">
+<li id="fn3">
+<p>Note c.</p>
+<blockquote>
+<p>quoted</p>
+</blockquote>
+<p><a href="#fnref3" role="doc-backlink">↩︎︎</a></p>
+</li>
+</ol>
+</section>
+</section>
+<section role="doc-endnotes">
+<hr>
+<ol>
+<li id="fn1">
+<p>Note a.<a href="#fnref1" role="doc-backlink">↩︎︎</a><a href="#fnref1-2" role="doc-backlink">↩︎︎</a></p>
+</li>
+</ol>
+</section>
+`, convert(SectionPlacement))
+	})
+	t.Run("sidenotes", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<p>Intro<a id="fnref1" href="#fn1" role="doc-noteref"><sup>1</sup></a><span class="sidenote" id="fn1" role="doc-footnote">Note a.</span>.</p>
+<section id="One">
+<h1>One</h1>
+<p>First<a id="fnref2" href="#fn2" role="doc-noteref"><sup>2</sup></a><span class="sidenote" id="fn2" role="doc-footnote">Note b<a id="fnref4" href="#fn4" role="doc-noteref"><sup>4</sup></a><span class="sidenote" id="fn4" role="doc-footnote">Note d.</span>.</span> and again<a id="fnref1-2" href="#fn1" role="doc-noteref"><sup>1</sup></a>.</p>
+</section>
+<section id="Two">
+<h1>Two</h1>
+<p>Second<a id="fnref3" href="#fn3" role="doc-noteref"><sup>3</sup></a><span class="sidenote" id="fn3" role="doc-footnote">Note c.<br>
+quoted</span>.</p>
+</section>
+`, convert(SidenotePlacement))
+	})
+	t.Run("separate", func(t *testing.T) {
+		context := NewConversionContext("html", DefaultConversionRegistry)
+		context.Options.Footnotes = SeparatePlacement
+		ast := BuildDjotAst([]byte("Text[^a].\n\n[^a]: Note.\n"))
+		testx.AssertEqual(t, "", "<p>Text<a id=\"fnref1\" href=\"#fn1\" role=\"doc-noteref\"><sup>1</sup></a>.</p>\n", context.ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
+		testx.AssertEqual(t, "", `<section role="doc-endnotes">
+<hr>
+<ol>
+<li id="fn1">
+<p>Note.<a href="#fnref1" role="doc-backlink">↩︎︎</a></p>
+</li>
+</ol>
+</section>
+`, context.Footnotes(&html_writer.HtmlWriter{}, ast...))
+	})
+}
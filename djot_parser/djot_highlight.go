@@ -0,0 +1,144 @@
+package djot_parser
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/html_writer"
+	"md0.org/djot/tokenizer"
+)
+
+const (
+	HighlightLinesKey = "hl"      // {hl="3-5,8"} marks lines of the code block
+	LineNumbersKey    = "linenos" // {linenos=true} enables line numbers for the code block
+
+	CodeLineClass        = "line"
+	HighlightedLineClass = "hl-line"
+	CodeLineNumberClass  = "line-number"
+	disabledLineNumbers  = "false"
+)
+
+type (
+	HighlightToken struct {
+		Class string // CSS class of the token, token is written as plain text if empty
+		Text  []byte
+	}
+	Highlighter interface {
+		// Highlight splits code into tokens; ok is false if language isn't supported
+		Highlight(language string, code []byte) (tokens []HighlightToken, ok bool)
+	}
+)
+
+func (state ConversionState) HighlightedCodeConverter() *html_writer.HtmlWriter {
+	code := state.Node.FullText()
+	tokens, ok := state.Options.Highlighter.Highlight(state.Node.Attributes.Get(djot_tokenizer.CodeLangKey), code)
+	if !ok {
+		tokens = []HighlightToken{{Text: code}}
+	}
+	attributes := make([]tokenizer.AttributeEntry, 0)
+	for _, entry := range state.Node.Attributes.Entries() {
+		if entry.Key != HighlightLinesKey && entry.Key != LineNumbersKey {
+			attributes = append(attributes, entry)
+		}
+	}
+	highlighted := parseLineRanges(state.Node.Attributes.Get(HighlightLinesKey))
+	lineNumbers, numbered := state.Node.Attributes.TryGet(LineNumbersKey)
+	numbered = numbered && lineNumbers != disabledLineNumbers
+
+	w := state.Writer
+	w.OpenTag("pre").OpenTag("code", attributes...)
+	if !numbered && len(highlighted) == 0 {
+		writeHighlightTokens(w, tokens)
+	} else {
+		lines := splitHighlightLines(tokens)
+		for i, line := range lines {
+			if i == len(lines)-1 && len(line) == 0 {
+				break
+			}
+			class := CodeLineClass
+			if highlighted.contains(i + 1) {
+				class += " " + HighlightedLineClass
+			}
+			w.OpenTag("span", tokenizer.AttributeEntry{Key: djot_tokenizer.DjotAttributeClassKey, Value: class})
+			if numbered {
+				w.OpenTag("span", tokenizer.AttributeEntry{Key: djot_tokenizer.DjotAttributeClassKey, Value: CodeLineNumberClass})
+				w.WriteString(strconv.Itoa(i + 1)).CloseTag("span")
+			}
+			writeHighlightTokens(w, line)
+			w.CloseTag("span")
+			if i < len(lines)-1 {
+				w.WriteString("\n")
+			}
+		}
+	}
+	return w.CloseTag("code").CloseTag("pre").WriteString("\n")
+}
+
+func writeHighlightTokens(w *html_writer.HtmlWriter, tokens []HighlightToken) {
+	for _, token := range tokens {
+		text := htmlReplacer.Replace(string(token.Text))
+		if token.Class == "" {
+			w.WriteString(text)
+		} else {
+			w.InTag("span", tokenizer.AttributeEntry{Key: djot_tokenizer.DjotAttributeClassKey, Value: token.Class})(func() {
+				w.WriteString(text)
+			})
+		}
+	}
+}
+
+// splitHighlightLines splits tokens by newlines (newline symbols are dropped) so every line can be wrapped separately
+func splitHighlightLines(tokens []HighlightToken) [][]HighlightToken {
+	lines := [][]HighlightToken{nil}
+	for _, token := range tokens {
+		parts := bytes.Split(token.Text, []byte("\n"))
+		for i, part := range parts {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if len(part) > 0 {
+				lines[len(lines)-1] = append(lines[len(lines)-1], HighlightToken{Class: token.Class, Text: part})
+			}
+		}
+	}
+	return lines
+}
+
+// lineRange is inclusive range of 1-based line numbers
+type lineRange struct{ start, end int }
+
+type lineRanges []lineRange
+
+func (ranges lineRanges) contains(line int) bool {
+	for _, r := range ranges {
+		if r.start <= line && line <= r.end {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLineRanges parses 1-based line ranges like "3-5,8" and silently ignores malformed parts, ranges are kept as
+// is so large ranges don't cost anything
+func parseLineRanges(spec string) lineRanges {
+	ranges := make(lineRanges, 0)
+	for _, part := range strings.Split(spec, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			continue
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil {
+				continue
+			}
+		}
+		if start <= end {
+			ranges = append(ranges, lineRange{start: start, end: end})
+		}
+	}
+	return ranges
+}
@@ -0,0 +1,16 @@
+package djot_parser
+
+import (
+	"testing"
+
+	"md0.org/djot/internal/testx"
+)
+
+func TestParseLineRanges(t *testing.T) {
+	ranges := parseLineRanges("3-5, 8,x,9-7,2-2000000000")
+	testx.AssertEqual(t, "", lineRanges{{3, 5}, {8, 8}, {2, 2000000000}}, ranges)
+	testx.AssertFalse(t, "", ranges.contains(1))
+	testx.AssertTrue(t, "", ranges.contains(4))
+	testx.AssertTrue(t, "", ranges.contains(1999999999))
+	testx.AssertFalse(t, "", parseLineRanges("1-2,").contains(3))
+}
@@ -13,12 +13,23 @@ type (
 	ConversionContext struct {
 		Format   string
 		Registry ConversionRegistry
+		Options  ConversionOptions
 	}
+	ConversionOptions struct {
+		Highlighter Highlighter   // CodeNode content is written as is if nil
+		Math        MathConverter // math is written as TeX between \( \) or \[ \] delimiters if nil
+		Footnotes   FootnotePlacement
+		Admonitions Admonitions // divs with these classes are written as callouts
+		Tables      TableOptions
+	}
+	// MathConverter returns markup for TeX math; on error math is written as TeX
+	MathConverter   func(tex string, display bool) (string, error)
 	ConversionState struct {
-		Format string
-		Writer *html_writer.HtmlWriter
-		Node   TreeNode[DjotNode]
-		Parent *TreeNode[DjotNode]
+		Format  string
+		Writer  *html_writer.HtmlWriter
+		Node    TreeNode[DjotNode]
+		Parent  *TreeNode[DjotNode]
+		Options ConversionOptions
 	}
 	Conversion         func(state ConversionState, next func(Children))
 	ConversionRegistry map[DjotNode]Conversion
@@ -33,6 +44,25 @@ func (state ConversionState) InlineNodeConverter(tag string, next func(c Childre
 	return state.Writer.InTag(tag, state.Node.Attributes.Entries()...)(func() { next(nil) })
 }
 
+func (state ConversionState) MathNodeConverter(display bool, next func(c Children)) *html_writer.HtmlWriter {
+	class, start, end := "math inline", "\\(", "\\)"
+	if display {
+		class, start, end = "math display", "\\[", "\\]"
+	}
+	attributes := append([]tokenizer.AttributeEntry{{Key: "class", Value: class}}, state.Node.Attributes.Entries()...)
+	return state.Writer.InTag("span", attributes...)(func() {
+		if state.Options.Math != nil {
+			if markup, err := state.Options.Math(string(state.Node.FullText()), display); err == nil {
+				state.Writer.WriteString(markup)
+				return
+			}
+		}
+		state.Writer.WriteString(start)
+		next(nil)
+		state.Writer.WriteString(end)
+	})
+}
+
 func (state ConversionState) BlockNodeConverter(tag string, next func(c Children)) *html_writer.HtmlWriter {
 	content := func() {
 		state.Writer.WriteString("\n")
@@ -85,28 +115,16 @@ var DefaultConversionRegistry = map[DjotNode]Conversion{
 	ImageNode:        func(s ConversionState, n func(c Children)) { s.StandaloneNodeConverter("img") },
 	LinkNode:         func(s ConversionState, n func(c Children)) { s.InlineNodeConverter("a", n) },
 	SpanNode:         func(s ConversionState, n func(c Children)) { s.InlineNodeConverter("span", n) },
-	DivNode:          func(s ConversionState, n func(c Children)) { s.BlockNodeConverter("div", n) },
 	TableCaptionNode: func(s ConversionState, n func(c Children)) { n(nil) },
 	TableNode: func(s ConversionState, n func(c Children)) {
-		if len(s.Node.Children) > 0 && s.Node.Children[0].Type == TableCaptionNode {
-			s.Writer.OpenTag("table", s.Node.Attributes.Entries()...)
-			s.Writer.WriteString("\n")
-			s.Writer.InTag("caption")(func() { n(s.Node.Children[:1]) })
-			s.Writer.WriteString("\n")
-			s.Writer.InTag("tbody")(func() { n(s.Node.Children[1:]) })
-			s.Writer.CloseTag("table")
-		} else {
-			s.BlockNodeConverter("table", n)
-		}
+		s.TableConverter(n)
 	},
 	TableRowNode: func(s ConversionState, n func(c Children)) { s.BlockNodeConverter("tr", n) },
 	TableHeaderNode: func(s ConversionState, n func(c Children)) {
-		s.InlineNodeConverter("th", n)
-		s.Writer.WriteString("\n")
+		s.TableCellConverter("th", n)
 	},
 	TableCellNode: func(s ConversionState, n func(c Children)) {
-		s.InlineNodeConverter("td", n)
-		s.Writer.WriteString("\n")
+		s.TableCellConverter("td", n)
 	},
 	TaskListNode:       func(s ConversionState, n func(c Children)) { s.BlockNodeConverter("ul", n) },
 	DefinitionListNode: func(s ConversionState, n func(c Children)) { s.BlockNodeConverter("dl", n) },
@@ -138,25 +156,19 @@ var DefaultConversionRegistry = map[DjotNode]Conversion{
 	DocumentNode:       func(s ConversionState, n func(c Children)) { n(nil) },
 	FootnoteDefNode:    func(s ConversionState, n func(c Children)) { n(nil) },
 	CodeNode: func(s ConversionState, n func(c Children)) {
+		if s.Options.Highlighter != nil {
+			s.HighlightedCodeConverter()
+			return
+		}
 		s.Writer.OpenTag("pre").OpenTag("code", s.Node.Attributes.Entries()...)
 		n(nil)
 		s.Writer.CloseTag("code").CloseTag("pre").WriteString("\n")
 	},
 	VerbatimNode: func(s ConversionState, n func(c Children)) {
 		if _, ok := s.Node.Attributes.TryGet(djot_tokenizer.InlineMathKey); ok {
-			attributes := append([]tokenizer.AttributeEntry{{Key: "class", Value: "math inline"}}, s.Node.Attributes.Entries()...)
-			s.Writer.InTag("span", attributes...)(func() {
-				s.Writer.WriteString("\\(")
-				n(nil)
-				s.Writer.WriteString("\\)")
-			})
+			s.MathNodeConverter(false, n)
 		} else if _, ok := s.Node.Attributes.TryGet(djot_tokenizer.DisplayMathKey); ok {
-			attributes := append([]tokenizer.AttributeEntry{{Key: "class", Value: "math display"}}, s.Node.Attributes.Entries()...)
-			s.Writer.InTag("span", attributes...)(func() {
-				s.Writer.WriteString("\\[")
-				n(nil)
-				s.Writer.WriteString("\\]")
-			})
+			s.MathNodeConverter(true, n)
 		} else if rawFormat := s.Node.Attributes.Get(RawInlineFormatKey); rawFormat == s.Format {
 			n(nil)
 		} else {
@@ -172,6 +184,15 @@ var DefaultConversionRegistry = map[DjotNode]Conversion{
 			next(nil)
 		}
 	},
+	DivNode: func(s ConversionState, n func(c Children)) {
+		if admonition, ok := s.Options.Admonitions.Find(s.Node); ok {
+			s.AdmonitionConverter(admonition, n)
+		} else {
+			s.BlockNodeConverter("div", n)
+		}
+	},
+	FigureNode:        func(s ConversionState, n func(c Children)) { s.FigureConverter(n) },
+	FigureCaptionNode: func(s ConversionState, n func(c Children)) { s.FigureCaptionConverter(n) },
 }
 
 func NewConversionContext(format string, converters ...map[DjotNode]Conversion) ConversionContext {
@@ -194,7 +215,7 @@ func (context ConversionContext) ConvertDjotToHtml(
 	builder *html_writer.HtmlWriter,
 	nodes ...TreeNode[DjotNode],
 ) string {
-	context.convertDjotToHtml(builder, nil, nodes...)
+	context.convertDjotToHtml(builder, nil, placeFootnotes(context.Options.Footnotes, nodes)...)
 	return builder.String()
 }
 
@@ -206,10 +227,11 @@ func (context ConversionContext) convertDjotToHtml(builder *html_writer.HtmlWrit
 			continue
 		}
 		state := ConversionState{
-			Format: context.Format,
-			Writer: builder,
-			Node:   currentNode,
-			Parent: parent,
+			Format:  context.Format,
+			Writer:  builder,
+			Node:    currentNode,
+			Parent:  parent,
+			Options: context.Options,
 		}
 		conversion(state, func(c Children) {
 			if len(c) == 0 {
@@ -0,0 +1,251 @@
+package djot_parser
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/tokenizer"
+)
+
+const (
+	IncludeKey          = "include" // {include="path"} div is replaced by the content of the file
+	IncludeRule         = "include"
+	DefaultIncludeDepth = 16
+)
+
+type (
+	includeDirective struct {
+		target string
+		rng    tokenizer.Range // from the attributes to the end of the div
+	}
+	includedDocument struct {
+		document   []byte
+		list       tokenizer.TokenList[djot_tokenizer.DjotToken]
+		directives []includeDirective
+		nodes      []TreeNode[DjotNode]
+		rng        tokenizer.Range // of the document node
+	}
+	includeResolver struct {
+		fsys        fs.FS
+		maxDepth    int
+		order       []string
+		documents   map[string]*includedDocument
+		diagnostics []Diagnostic
+		reported    map[Diagnostic]bool
+	}
+)
+
+// BuildDjotAstWithIncludes parses the file and replaces divs with {include="path"} attribute by the content of the
+// included file, path is relative to the including file and can't leave the file system root. All files share
+// references and footnotes like parts of the single document; cycles, too deep nesting (maxDepth, DefaultIncludeDepth
+// if not positive) and unreadable files are reported as diagnostics and such divs are left with their own content.
+// Diagnostics point into the file where problem was found. Error is returned only if the file itself can't be read.
+// Options apply to all files.
+func BuildDjotAstWithIncludes(fsys fs.FS, name string, maxDepth int, options ParseOptions) ([]TreeNode[DjotNode], []Diagnostic, error) {
+	document, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	ast, diagnostics := BuildJoinedDjotAstWithIncludes(fsys, []string{name}, [][]byte{document}, maxDepth, options)
+	return ast, diagnostics, nil
+}
+
+// BuildJoinedDjotAstWithIncludes joins documents like BuildJoinedDjotAst and resolves their includes like
+// BuildDjotAstWithIncludes. Documents are already read, names place them in the file system to resolve their includes
+// (empty name stands for the document in the root, like standard input). Each document shares section ids with the
+// files it includes first, ids which were used by previous documents get numeric suffix.
+func BuildJoinedDjotAstWithIncludes(fsys fs.FS, names []string, documents [][]byte, maxDepth int, options ParseOptions) ([]TreeNode[DjotNode], []Diagnostic) {
+	r := newIncludeResolver(fsys, maxDepth)
+	chapters := make([][]string, len(names))
+	for i, name := range names {
+		loaded := len(r.order)
+		if _, ok := r.documents[name]; !ok {
+			r.load(name, documents[i])
+		}
+		r.resolve(name, []string{name})
+		chapters[i] = r.order[loaded:]
+	}
+
+	context := newDjotContext()
+	context.Options = options
+	for _, file := range r.order {
+		context.add(r.documents[file].document, r.documents[file].list)
+	}
+	context.numberFootnotes()
+	diagnostics := newDiagnosticsBuilder(context)
+	used := make(map[string]bool)
+	footnotes := make([]TreeNode[DjotNode], 0)
+	for _, chapter := range chapters {
+		chapterDocuments := make([][]byte, len(chapter))
+		lists := make([]tokenizer.TokenList[djot_tokenizer.DjotToken], len(chapter))
+		for i, file := range chapter {
+			chapterDocuments[i], lists[i] = r.documents[file].document, r.documents[file].list
+		}
+		for i, documentContext := range uniqueSectionIds(context, used, chapterDocuments, lists) {
+			included := r.documents[chapter[i]]
+			// headings renamed to unique ids aren't duplicates
+			diagnostics.context = documentContext
+			diagnostics.add(chapter[i], included.document, included.list)
+			for _, node := range buildDjotAst(included.document, documentContext, DjotLocalContext{}, included.list) {
+				included.rng = node.Range
+				nodes := node.Children
+				if last := len(nodes) - 1; last >= 0 && nodes[last].Type == SectionNode && nodes[last].Attributes.Get(RoleKey) == EndnotesRole {
+					footnotes = append(footnotes, nodes[last].Children[1].Children...)
+					nodes = nodes[:last]
+				}
+				included.nodes = append(included.nodes, nodes...)
+			}
+		}
+	}
+	children := make([]TreeNode[DjotNode], 0)
+	for _, name := range names {
+		children = append(children, r.splice(name, r.documents[name].nodes, []string{name})...)
+	}
+	if len(footnotes) > 0 {
+		children = append(children, endnotesSection(footnotes))
+	}
+	root := TreeNode[DjotNode]{Type: DocumentNode, Children: children}
+	if len(names) == 1 {
+		root.Range = r.documents[names[0]].rng
+	}
+	diagnostics.diagnostics = append(diagnostics.diagnostics, r.diagnostics...)
+	return options.transform(linkFootnotes([]TreeNode[DjotNode]{root})), diagnostics.build()
+}
+
+// IncludedFiles lists files included by the file directly or through other included files, in the order
+// BuildDjotAstWithIncludes reads them; files which can't be included are skipped. Error is returned only if the file
+// itself can't be read.
+func IncludedFiles(fsys fs.FS, name string, maxDepth int) ([]string, error) {
+	document, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	r := newIncludeResolver(fsys, maxDepth)
+	r.load(name, document)
+	r.resolve(name, []string{name})
+	return r.order[1:], nil
+}
+
+func newIncludeResolver(fsys fs.FS, maxDepth int) *includeResolver {
+	if maxDepth <= 0 {
+		maxDepth = DefaultIncludeDepth
+	}
+	return &includeResolver{
+		fsys:      fsys,
+		maxDepth:  maxDepth,
+		documents: make(map[string]*includedDocument),
+		reported:  make(map[Diagnostic]bool),
+	}
+}
+
+// includeTarget resolves path of the included file relative to the including one
+func includeTarget(file, include string) (string, bool) {
+	target := path.Join(path.Dir(file), include)
+	return target, !path.IsAbs(include) && fs.ValidPath(target)
+}
+
+func (r *includeResolver) report(diagnostic Diagnostic) {
+	// file included from the several places is resolved several times and can report the same problem again
+	if !r.reported[diagnostic] {
+		r.reported[diagnostic] = true
+		r.diagnostics = append(r.diagnostics, diagnostic)
+	}
+}
+
+func (r *includeResolver) load(file string, document []byte) {
+	included := &includedDocument{document: document, list: djot_tokenizer.BuildDjotTokens(document)}
+	var attributes tokenizer.Attributes
+	attributesStart := -1
+	for i, token := range included.list {
+		if token.Type == djot_tokenizer.Attribute {
+			if attributesStart == -1 {
+				attributesStart = token.Start
+			}
+			attributes.MergeWith(token.Attributes)
+			continue
+		}
+		if include, ok := attributes.TryGet(IncludeKey); ok && token.Type == djot_tokenizer.DivBlock {
+			included.directives = append(included.directives, includeDirective{
+				target: include,
+				rng:    tokenizer.Range{Start: attributesStart, End: included.list[i+token.JumpToPair].End},
+			})
+		}
+		attributes, attributesStart = tokenizer.Attributes{}, -1
+	}
+	r.order = append(r.order, file)
+	r.documents[file] = included
+}
+
+// resolve loads files included by the file; stack contains chain of the files which includes current one
+func (r *includeResolver) resolve(file string, stack []string) {
+	for _, directive := range r.documents[file].directives {
+		report := func(format string, args ...any) {
+			r.report(Diagnostic{
+				Severity: ErrorSeverity,
+				Rule:     IncludeRule,
+				Message:  fmt.Sprintf(format, args...),
+				Range:    directive.rng,
+				File:     file,
+			})
+		}
+		target, ok := includeTarget(file, directive.target)
+		switch {
+		case !ok:
+			report("invalid include path '%v'", directive.target)
+		case slices.Contains(stack, target):
+			report("include cycle: %v", strings.Join(append(stack, target), " -> "))
+		case len(stack) > r.maxDepth:
+			report("include depth limit %v exceeded", r.maxDepth)
+		default:
+			if _, ok := r.documents[target]; !ok {
+				document, err := fs.ReadFile(r.fsys, target)
+				if err != nil {
+					report("failed to include '%v': %v", directive.target, err)
+					continue
+				}
+				r.load(target, document)
+			}
+			r.resolve(target, append(stack, target))
+		}
+	}
+}
+
+// splice replaces include divs with nodes of included files using the same rules as resolve
+func (r *includeResolver) splice(file string, nodes []TreeNode[DjotNode], stack []string) []TreeNode[DjotNode] {
+	result := make([]TreeNode[DjotNode], 0, len(nodes))
+	for _, node := range nodes {
+		include, ok := node.Attributes.TryGet(IncludeKey)
+		if !ok || node.Type != DivNode {
+			node.Children = r.splice(file, node.Children, stack)
+			result = append(result, node)
+			continue
+		}
+		var attributes tokenizer.Attributes
+		for _, entry := range node.Attributes.Entries() {
+			if entry.Key != IncludeKey {
+				attributes.Set(entry.Key, entry.Value)
+			}
+		}
+		node.Attributes = attributes
+		target, ok := includeTarget(file, include)
+		included, loaded := r.documents[target]
+		if !ok || !loaded || slices.Contains(stack, target) || len(stack) > r.maxDepth {
+			node.Children = r.splice(file, node.Children, stack)
+			result = append(result, node)
+			continue
+		}
+		children := r.splice(target, included.nodes, append(stack, target))
+		if attributes.Size() == 0 {
+			result = append(result, children...)
+		} else {
+			// div with other attributes is kept as a wrapper of included content
+			node.Children = children
+			result = append(result, node)
+		}
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package djot_parser
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"md0.org/djot/html_writer"
+	"md0.org/djot/internal/testx"
+	"md0.org/djot/tokenizer"
+)
+
+func TestBuildDjotAstWithIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"manual/index.djot":          {Data: []byte("Intro[^a]\n\n{include=\"shared/warning.djot\"}\n:::\n:::\n\n{include=\"missing.djot\" .fallback}\n:::\nfallback\n:::\n\n[^a]: Note.\n")},
+		"manual/shared/warning.djot": {Data: []byte("{.warning}\n::: \nSee [site][].\n:::\n\n{include=\"../cycle.djot\"}\n:::\n:::\n")},
+		"manual/cycle.djot":          {Data: []byte("{include=\"shared/warning.djot\"}\n:::\n:::\n\n[site]: https://example.com\n\n[b]: unused\n")},
+	}
+	ast, diagnostics, err := BuildDjotAstWithIncludes(fsys, "manual/index.djot", 0, ParseOptions{})
+	testx.AssertNilError(t, "", err)
+	testx.AssertEqual(t, "", `<p>Intro<a id="fnref1" href="#fn1" role="doc-noteref"><sup>1</sup></a></p>
+<div class="warning">
+<p>See <a href="https://example.com">site</a>.</p>
+</div>
+<div>
+</div>
+<div class="fallback">
+<p>fallback</p>
+</div>
+<section role="doc-endnotes">
+<hr>
+<ol>
+<li id="fn1">
+<p>Note.<a href="#fnref1" role="doc-backlink">↩︎︎</a></p>
+</li>
+</ol>
+</section>
+`, NewConversionContext("html", DefaultConversionRegistry).ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
+	testx.AssertEqual(t, "", []Diagnostic{
+		{Severity: ErrorSeverity, Rule: IncludeRule, Message: "failed to include 'missing.djot': open manual/missing.djot: file does not exist", Range: tokenizer.Range{Start: 52, End: 104}, File: "manual/index.djot"},
+		{Severity: ErrorSeverity, Rule: IncludeRule, Message: "include cycle: manual/index.djot -> manual/shared/warning.djot -> manual/cycle.djot -> manual/shared/warning.djot", Range: tokenizer.Range{Start: 0, End: 40}, File: "manual/cycle.djot"},
+		{Severity: WarningSeverity, Rule: UnusedReferenceRule, Message: "reference 'b' is never used", Range: tokenizer.Range{Start: 70, End: 82}, File: "manual/cycle.djot"},
+	}, diagnostics)
+
+	t.Run("depth limit", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"a.djot": {Data: []byte("{include=\"b.djot\"}\n:::\n:::\n")},
+			"b.djot": {Data: []byte("{include=\"c.djot\"}\n:::\n:::\n")},
+			"c.djot": {Data: []byte("c\n")},
+		}
+		_, diagnostics, err := BuildDjotAstWithIncludes(fsys, "a.djot", 1, ParseOptions{})
+		testx.AssertNilError(t, "", err)
+		testx.AssertEqual(t, "", 1, len(diagnostics))
+		testx.AssertEqual(t, "", "include depth limit 1 exceeded", diagnostics[0].Message)
+		testx.AssertEqual(t, "", "b.djot", diagnostics[0].File)
+	})
+	t.Run("invalid path", func(t *testing.T) {
+		_, diagnostics, _ := BuildDjotAstWithIncludes(fstest.MapFS{"a.djot": {Data: []byte("{include=\"../a.djot\"}\n:::\n:::\n")}}, "a.djot", 0, ParseOptions{})
+		testx.AssertEqual(t, "", "invalid include path '../a.djot'", diagnostics[0].Message)
+		_, _, err := BuildDjotAstWithIncludes(fstest.MapFS{}, "a.djot", 0, ParseOptions{})
+		testx.AssertNotNil(t, "", err)
+	})
+	t.Run("joined", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"a/part.djot": {Data: []byte("# Part\n\nSee [Part][].\n")},
+			"b/part.djot": {Data: []byte("# Part\n\nSee [Part][] too.\n")},
+		}
+		ast, diagnostics := BuildJoinedDjotAstWithIncludes(fsys, []string{"a/doc.djot", ""}, [][]byte{
+			[]byte("{include=\"part.djot\"}\n:::\n:::\n"),
+			[]byte("{include=\"b/part.djot\"}\n:::\n:::\n"),
+		}, 0, ParseOptions{})
+		testx.AssertEqual(t, "", 0, len(diagnostics))
+		testx.AssertEqual(t, "", `<section id="Part">
+<h1>Part</h1>
+<p>See <a href="#Part">Part</a>.</p>
+</section>
+<section id="Part-1">
+<h1>Part</h1>
+<p>See <a href="#Part-1">Part</a> too.</p>
+</section>
+`, NewConversionContext("html", DefaultConversionRegistry).ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
+	})
+	t.Run("included files", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"a.djot":      {Data: []byte("{include=\"b/b.djot\"}\n:::\n:::\n\n{include=\"missing.djot\"}\n:::\n:::\n")},
+			"b/b.djot":    {Data: []byte("{include=\"c.djot\"}\n:::\n:::\n")},
+			"b/c.djot":    {Data: []byte("c\n")},
+			"unused.djot": {Data: []byte("u\n")},
+		}
+		files, err := IncludedFiles(fsys, "a.djot", 0)
+		testx.AssertNilError(t, "", err)
+		testx.AssertEqual(t, "", []string{"b/b.djot", "b/c.djot"}, files)
+		_, err = IncludedFiles(fsys, "missing.djot", 0)
+		testx.AssertNotNil(t, "", err)
+	})
+}
@@ -0,0 +1,182 @@
+package djot_parser
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/tokenizer"
+)
+
+const AutolinkClass = "autolink" // class of links created from bare urls and email addresses
+
+// Linkify turns bare urls (starting with http://, https:// or www.) and email addresses in the text into links
+// with AutolinkClass. Trailing punctuation and unbalanced closing parentheses are left out of the link, text
+// inside of links, code, raw and verbatim nodes is left untouched. Nodes are modified in place.
+func Linkify(ast []TreeNode[DjotNode]) []TreeNode[DjotNode] {
+	for i := range ast {
+		switch ast[i].Type {
+		case CodeNode, RawNode, VerbatimNode, SymbolsNode, LinkNode, ImageNode:
+			continue
+		}
+		ast[i].Children = linkifyText(Linkify(ast[i].Children))
+	}
+	return ast
+}
+
+// linkifyText joins adjacent text nodes (smart punctuation splits text into several nodes) and replaces links in them
+func linkifyText(nodes []TreeNode[DjotNode]) []TreeNode[DjotNode] {
+	result := make([]TreeNode[DjotNode], 0, len(nodes))
+	for start := 0; start < len(nodes); {
+		if nodes[start].Type != TextNode {
+			result = append(result, nodes[start])
+			start++
+			continue
+		}
+		end, text := start, []byte(nil)
+		for end < len(nodes) && nodes[end].Type == TextNode {
+			text = append(text, nodes[end].Text...)
+			end++
+		}
+		if links := findLinks(text); len(links) > 0 {
+			result = append(result, linkNodes(text, links)...)
+		} else {
+			result = append(result, nodes[start:end]...)
+		}
+		start = end
+	}
+	return result
+}
+
+type textLink struct {
+	start, end int
+	href       string
+}
+
+func findLinks(text []byte) []textLink {
+	links := make([]textLink, 0)
+	for i := 0; i < len(text); {
+		if isLinkStart(text, i) {
+			link, ok := matchUrl(text, i)
+			if !ok {
+				link, ok = matchEmail(text, i)
+			}
+			if ok {
+				links = append(links, link)
+				i = link.end
+				continue
+			}
+		}
+		_, size := utf8.DecodeRune(text[i:])
+		i += size
+	}
+	return links
+}
+
+func linkNodes(text []byte, links []textLink) []TreeNode[DjotNode] {
+	nodes := make([]TreeNode[DjotNode], 0, 2*len(links)+1)
+	previous := 0
+	for _, link := range links {
+		if link.start > previous {
+			nodes = append(nodes, TreeNode[DjotNode]{Type: TextNode, Text: text[previous:link.start]})
+		}
+		nodes = append(nodes, TreeNode[DjotNode]{
+			Type: LinkNode,
+			Attributes: tokenizer.NewAttributes(
+				tokenizer.AttributeEntry{Key: LinkHrefKey, Value: link.href},
+				tokenizer.AttributeEntry{Key: djot_tokenizer.DjotAttributeClassKey, Value: AutolinkClass},
+			),
+			Children: []TreeNode[DjotNode]{{Type: TextNode, Text: text[link.start:link.end]}},
+		})
+		previous = link.end
+	}
+	if previous < len(text) {
+		nodes = append(nodes, TreeNode[DjotNode]{Type: TextNode, Text: text[previous:]})
+	}
+	return nodes
+}
+
+// isLinkStart checks that link can start at the position: links start at the beginning of the text, after whitespace,
+// opening brackets, quotes or emphasis delimiters
+func isLinkStart(text []byte, i int) bool {
+	if i == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRune(text[:i])
+	return unicode.IsSpace(r) || strings.ContainsRune("(*_~\"'[<", r) || (r >= utf8.RuneSelf && unicode.IsPunct(r))
+}
+
+// isLinkEnd checks that rune can't be part of the url: non-ASCII punctuation (smart quotes, ellipsis) ends url too
+func isLinkEnd(r rune) bool {
+	return unicode.IsSpace(r) || r == '<' || (r >= utf8.RuneSelf && unicode.IsPunct(r))
+}
+
+func matchUrl(text []byte, start int) (textLink, bool) {
+	rest := text[start:]
+	for _, prefix := range []string{"https://", "http://", "www."} {
+		if len(rest) <= len(prefix) || !strings.EqualFold(string(rest[:len(prefix)]), prefix) {
+			continue
+		}
+		if r, _ := utf8.DecodeRune(rest[len(prefix):]); !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return textLink{}, false
+		}
+		end := len(prefix)
+		for end < len(rest) {
+			r, size := utf8.DecodeRune(rest[end:])
+			if isLinkEnd(r) {
+				break
+			}
+			end += size
+		}
+		end = trimLinkEnd(rest[:end])
+		href := string(rest[:end])
+		if prefix == "www." {
+			href = "http://" + href
+		}
+		return textLink{start: start, end: start + end, href: href}, true
+	}
+	return textLink{}, false
+}
+
+// trimLinkEnd strips trailing punctuation and closing parentheses without pair from the link
+func trimLinkEnd(link []byte) int {
+	end := len(link)
+	for end > 0 {
+		switch c := link[end-1]; {
+		case strings.IndexByte("?!.,:;*_~'\"", c) >= 0:
+			end--
+		case c == ')' && bytes.Count(link[:end], []byte(")")) > bytes.Count(link[:end], []byte("(")):
+			end--
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+func isAlphanumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func matchEmail(text []byte, start int) (textLink, bool) {
+	at := start
+	for at < len(text) && (isAlphanumeric(text[at]) || strings.IndexByte(".+-_", text[at]) >= 0) {
+		at++
+	}
+	if at == start || at+1 >= len(text) || text[at] != '@' || !isAlphanumeric(text[at+1]) {
+		return textLink{}, false
+	}
+	end := at + 1
+	for end < len(text) && (isAlphanumeric(text[end]) || strings.IndexByte(".-_", text[end]) >= 0) {
+		end++
+	}
+	for text[end-1] == '.' {
+		end--
+	}
+	if domain := text[at+1 : end]; !bytes.Contains(domain, []byte(".")) || !isAlphanumeric(domain[len(domain)-1]) {
+		return textLink{}, false
+	}
+	return textLink{start: start, end: end, href: "mailto:" + string(text[start:end])}, true
+}
@@ -0,0 +1,44 @@
+package djot_parser
+
+import (
+	"testing"
+
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/html_writer"
+	"md0.org/djot/internal/testx"
+)
+
+func TestLinkify(t *testing.T) {
+	convert := func(document string) string {
+		ast := BuildDjotAstWithOptions([]byte(document), ParseOptions{Linkify: true})
+		return NewConversionContext("html").ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...)
+	}
+	t.Run("urls", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<p>See <a class="autolink" href="https://example.com/a-b?x=1">https://example.com/a-b?x=1</a>, <a class="autolink" href="http://www.example.org">www.example.org</a>.</p>
+`, convert("See https://example.com/a-b?x=1, www.example.org."))
+	})
+	t.Run("parentheses", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<p>(<a class="autolink" href="https://en.wikipedia.org/wiki/Go_(language)">https://en.wikipedia.org/wiki/Go_(language)</a>) (<a class="autolink" href="http://www.x.org">www.x.org</a>)</p>
+`, convert("(https://en.wikipedia.org/wiki/Go_(language)) (www.x.org)"))
+	})
+	t.Run("emails", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<p>Write to <a class="autolink" href="mailto:first.last+djot@mail.example.com">first.last+djot@mail.example.com</a>. Not a@b or a@b-.</p>
+`, convert("Write to first.last+djot@mail.example.com. Not a@b or a@b-."))
+	})
+	t.Run("smart punctuation", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<p>&ldquo;<a class="autolink" href="https://example.com/a">https://example.com/a</a>&rdquo; <a class="autolink" href="https://example.com/b">https://example.com/b</a>&hellip;</p>
+`, convert(`"https://example.com/a" https://example.com/b...`))
+	})
+	t.Run("untouched", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<p><a href="https://a.org">https://a.org</a> <code>https://b.org</code> <a href="https://c.org">see https://c.org</a> xhttps://d.org</p>
+`, convert("<https://a.org> `https://b.org` [see https://c.org](https://c.org) xhttps://d.org"))
+		testx.AssertEqual(t, "", "<p>https://example.com</p>\n", NewConversionContext("html").ConvertDjotToHtml(&html_writer.HtmlWriter{}, BuildDjotAst([]byte("https://example.com"))...))
+	})
+	t.Run("marked", func(t *testing.T) {
+		ast := BuildDjotAstWithOptions([]byte("<https://a.org> https://b.org"), ParseOptions{Linkify: true})
+		links := ast[0].Children[0].Children
+		_, ok := links[0].Attributes.TryGet(djot_tokenizer.DjotAttributeClassKey)
+		testx.AssertFalse(t, "", ok)
+		testx.AssertEqual(t, "", AutolinkClass, links[2].Attributes.Get(djot_tokenizer.DjotAttributeClassKey))
+	})
+}
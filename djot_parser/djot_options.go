@@ -0,0 +1,136 @@
+package djot_parser
+
+import (
+	"strings"
+	"unicode"
+)
+
+type (
+	// ParseOptions changes how text is converted into AST, zero value gives default behaviour
+	ParseOptions struct {
+		Quotes             QuoteStyle // EnglishQuotes if empty
+		NoSmartPunctuation bool       // quotes, dashes and ellipsis are left as typed
+		Slug               SlugFunc   // CreateSectionId if nil
+		Linkify            bool       // bare urls and email addresses become links, see Linkify
+		Figures            bool       // standalone images and divs with figure class become figures, see Figures
+		CSV                bool       // ```=csv raw blocks and ::: csv divs (or tsv) become tables, see CSVTable
+		// CrossReferences numbers figures, tables and sections with these labels and makes explicit ids reference
+		// labels, see NumberCrossReferences
+		CrossReferences *CrossReferenceLabels
+	}
+	// SlugFunc converts heading text into the section id, which is also used as implicit reference to the heading
+	SlugFunc func(text string) string
+	// QuoteStyle contains replacements for straight quotes, spaces required by the typography (like in French)
+	// are part of the replacement
+	QuoteStyle struct {
+		OpenDouble, CloseDouble string
+		OpenSingle, CloseSingle string
+	}
+)
+
+var (
+	EnglishQuotes = QuoteStyle{OpenDouble: "“", CloseDouble: "”", OpenSingle: "‘", CloseSingle: "’"}
+	GermanQuotes  = QuoteStyle{OpenDouble: "„", CloseDouble: "“", OpenSingle: "‚", CloseSingle: "‘"}
+	// FrenchQuotes uses narrow no-break spaces inside of guillemets
+	FrenchQuotes   = QuoteStyle{OpenDouble: "«\u202f", CloseDouble: "\u202f»", OpenSingle: "‹\u202f", CloseSingle: "\u202f›"}
+	SwedishQuotes  = QuoteStyle{OpenDouble: "”", CloseDouble: "”", OpenSingle: "’", CloseSingle: "’"}
+	PolishQuotes   = QuoteStyle{OpenDouble: "„", CloseDouble: "”", OpenSingle: "‚", CloseSingle: "’"}
+	RussianQuotes  = QuoteStyle{OpenDouble: "«", CloseDouble: "»", OpenSingle: "„", CloseSingle: "“"}
+	SwissQuotes    = QuoteStyle{OpenDouble: "«", CloseDouble: "»", OpenSingle: "‹", CloseSingle: "›"}
+	JapaneseQuotes = QuoteStyle{OpenDouble: "『", CloseDouble: "』", OpenSingle: "「", CloseSingle: "」"}
+
+	// LocaleQuotes maps language codes to their quote styles
+	LocaleQuotes = map[string]QuoteStyle{
+		"en":    EnglishQuotes,
+		"de":    GermanQuotes,
+		"de-ch": SwissQuotes,
+		"cs":    GermanQuotes,
+		"fr":    FrenchQuotes,
+		"sv":    SwedishQuotes,
+		"fi":    SwedishQuotes,
+		"pl":    PolishQuotes,
+		"nl":    PolishQuotes,
+		"ru":    RussianQuotes,
+		"uk":    RussianQuotes,
+		"ja":    JapaneseQuotes,
+	}
+)
+
+// QuoteStyleForLocale finds quote style by locale like "de", "de-CH" or "fr_FR", style of the region is preferred to
+// the style of the language
+func QuoteStyleForLocale(locale string) (QuoteStyle, bool) {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if style, ok := LocaleQuotes[locale]; ok {
+		return style, true
+	}
+	language, _, _ := strings.Cut(locale, "-")
+	style, ok := LocaleQuotes[language]
+	return style, ok
+}
+
+// SectionId creates id of the section with the heading text using configured slug strategy
+func (o ParseOptions) SectionId(text string) string {
+	if o.Slug == nil {
+		return CreateSectionId(text)
+	}
+	return o.Slug(text)
+}
+
+// GitHubSlug creates anchors compatible with GitHub: text is lowercased, punctuation is removed and every
+// whitespace becomes a dash; duplicated headings aren't numbered
+func GitHubSlug(text string) string {
+	slug := strings.Builder{}
+	for _, c := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.Is(unicode.Mn, c) || c == '-' || c == '_':
+			slug.WriteRune(c)
+		case unicode.IsSpace(c):
+			slug.WriteRune('-')
+		}
+	}
+	return slug.String()
+}
+
+var latinTransliteration = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'ç': "c", 'ć': "c", 'ĉ': "c", 'ċ': "c", 'č': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g",
+	'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i",
+	'ĵ': "j", 'ķ': "k",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r",
+	'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ș': "s",
+	'ţ': "t", 'ť': "t", 'ŧ': "t", 'ț': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'þ': "th",
+}
+
+// ASCIISlug creates lowercase ASCII ids: common Latin letters with diacritics are transliterated, other symbols are
+// replaced with single dash between words
+func ASCIISlug(text string) string {
+	slug := strings.Builder{}
+	dash := false
+	write := func(s string) {
+		if dash && slug.Len() > 0 {
+			slug.WriteByte('-')
+		}
+		dash = false
+		slug.WriteString(s)
+	}
+	for _, c := range strings.ToLower(text) {
+		if c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			write(string(c))
+		} else if transliteration, ok := latinTransliteration[c]; ok {
+			write(transliteration)
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
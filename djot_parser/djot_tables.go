@@ -0,0 +1,159 @@
+package djot_parser
+
+import (
+	"bytes"
+
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/html_writer"
+	"md0.org/djot/tokenizer"
+)
+
+const AlignmentClassPrefix = "align-"
+
+type (
+	// TableOptions changes html structure of the tables, zero value gives structure of the reference implementation
+	TableOptions struct {
+		Sections  bool // leading header rows are written into <thead> and other rows into <tbody>
+		Alignment TableAlignment
+	}
+	// TableAlignment is the way column alignment is written
+	TableAlignment int
+)
+
+const (
+	StyleAlignment  TableAlignment = iota // inline text-align style of every cell
+	ClassAlignment                        // align-left, align-center or align-right class of every cell
+	ColumnAlignment                       // <colgroup> with <col class="align-..."> for every column, cells are left as is
+)
+
+// splitTableRowAttributes separates attributes written right after the last separator of the row, like | a |{.total}
+func splitTableRowAttributes(
+	document []byte,
+	list tokenizer.TokenList[djot_tokenizer.DjotToken],
+) (tokenizer.Attributes, tokenizer.TokenList[djot_tokenizer.DjotToken]) {
+	var attributes tokenizer.Attributes
+	end := len(list)
+	for end > 0 && list[end-1].Type == djot_tokenizer.Attribute {
+		end--
+	}
+	if end == len(list) || end == 0 || list[end-1].Type != djot_tokenizer.None || string(document[list[end-1].Start:list[end-1].End]) != "|" {
+		return attributes, list
+	}
+	for _, token := range list[end:] {
+		attributes.MergeWith(token.Attributes)
+	}
+	return attributes, list[:end-1]
+}
+
+// splitTableCellAttributes separates attributes which end the cell content after whitespace, like | 42 {.total} |
+func splitTableCellAttributes(
+	document []byte,
+	list tokenizer.TokenList[djot_tokenizer.DjotToken],
+) (tokenizer.Attributes, tokenizer.TokenList[djot_tokenizer.DjotToken]) {
+	var attributes tokenizer.Attributes
+	last := len(list) - 1
+	if last < 0 || list[last].Type != djot_tokenizer.Attribute {
+		return attributes, list
+	}
+	if last == 0 {
+		return list[last].Attributes, nil
+	}
+	previous := list[last-1]
+	text := document[previous.Start:previous.End]
+	trimmed := bytes.TrimRight(text, " \t")
+	if previous.Type != djot_tokenizer.None || len(trimmed) == len(text) {
+		return attributes, list
+	}
+	previous.End -= len(text) - len(trimmed)
+	// copy the list, so trimmed token doesn't change the list of the document
+	return list[last].Attributes, trimPadding(document, append(list[:last-1:last-1], previous))
+}
+
+func isTableHeaderRow(row TreeNode[DjotNode]) bool {
+	for _, cell := range row.Children {
+		if cell.Type != TableHeaderNode {
+			return false
+		}
+	}
+	return row.Type == TableRowNode && len(row.Children) > 0
+}
+
+// TableConverter writes <table> with optional caption, columns and sections depending on TableOptions
+func (state ConversionState) TableConverter(next func(c Children)) *html_writer.HtmlWriter {
+	rows, caption := state.Node.Children, Children(nil)
+	if len(rows) > 0 && rows[0].Type == TableCaptionNode {
+		rows, caption = rows[1:], rows[:1]
+	}
+	content := func() {
+		state.Writer.WriteString("\n")
+		if len(caption) > 0 {
+			state.Writer.InTag("caption")(func() { next(caption) }).WriteString("\n")
+		}
+		if state.Options.Tables.Alignment == ColumnAlignment && len(rows) > 0 {
+			state.Writer.InTag("colgroup")(func() {
+				state.Writer.WriteString("\n")
+				for _, cell := range rows[0].Children {
+					if alignment := cell.Attributes.Get(TableAlignmentKey); alignment != DefaultAlignment {
+						state.Writer.OpenTag("col", tokenizer.AttributeEntry{Key: djot_tokenizer.DjotAttributeClassKey, Value: AlignmentClassPrefix + alignment})
+					} else {
+						state.Writer.OpenTag("col")
+					}
+					state.Writer.WriteString("\n")
+				}
+			}).WriteString("\n")
+		}
+		if len(rows) == 0 {
+			return
+		}
+		if !state.Options.Tables.Sections {
+			if len(caption) > 0 {
+				state.Writer.InTag("tbody")(func() { next(rows) })
+			} else {
+				next(rows)
+			}
+			return
+		}
+		header := 0
+		for header < len(rows) && isTableHeaderRow(rows[header]) {
+			header++
+		}
+		for _, section := range []struct {
+			tag  string
+			rows Children
+		}{{"thead", rows[:header]}, {"tbody", rows[header:]}} {
+			if len(section.rows) > 0 {
+				state.Writer.InTag(section.tag)(func() {
+					state.Writer.WriteString("\n")
+					next(section.rows)
+				}).WriteString("\n")
+			}
+		}
+	}
+	return state.Writer.InTag("table", state.Node.Attributes.Entries()...)(content).WriteString("\n")
+}
+
+// TableCellConverter writes <td> or <th> with the alignment of the column depending on TableOptions
+func (state ConversionState) TableCellConverter(tag string, next func(c Children)) *html_writer.HtmlWriter {
+	attributes := state.Node.Attributes.Entries()
+	if alignment := state.Node.Attributes.Get(TableAlignmentKey); alignment != DefaultAlignment {
+		var entry tokenizer.AttributeEntry
+		switch state.Options.Tables.Alignment {
+		case StyleAlignment:
+			entry = tokenizer.AttributeEntry{Key: "style", Value: "text-align: " + alignment + ";"}
+		case ClassAlignment:
+			entry = tokenizer.AttributeEntry{Key: djot_tokenizer.DjotAttributeClassKey, Value: AlignmentClassPrefix + alignment}
+		}
+		if entry.Key != "" {
+			attributes = append([]tokenizer.AttributeEntry{entry}, attributes...)
+			// alignment is joined with the attribute of the cell with the same key
+			for i := 1; i < len(attributes); i++ {
+				if attributes[i].Key == entry.Key {
+					attributes[0].Value += " " + attributes[i].Value
+					attributes = append(attributes[:i], attributes[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return state.Writer.InTag(tag, attributes...)(func() { next(nil) }).WriteString("\n")
+}
@@ -0,0 +1,77 @@
+package djot_parser
+
+import (
+	"testing"
+
+	"md0.org/djot/html_writer"
+	"md0.org/djot/internal/testx"
+)
+
+func TestTables(t *testing.T) {
+	convert := func(document string, options TableOptions) string {
+		context := NewConversionContext("html")
+		context.Options.Tables = options
+		return context.ConvertDjotToHtml(&html_writer.HtmlWriter{}, BuildDjotAst([]byte(document))...)
+	}
+	table := "| a | b |\n|:--|--:|\n| 1 | 2 |\n\n^ Sizes\n"
+	t.Run("default", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<table>
+<caption>Sizes</caption>
+<tbody><tr>
+<th style="text-align: left;">a</th>
+<th style="text-align: right;">b</th>
+</tr>
+<tr>
+<td style="text-align: left;">1</td>
+<td style="text-align: right;">2</td>
+</tr>
+</tbody></table>
+`, convert(table, TableOptions{}))
+	})
+	t.Run("sections and classes", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<table>
+<caption>Sizes</caption>
+<thead>
+<tr>
+<th class="align-left">a</th>
+<th class="align-right">b</th>
+</tr>
+</thead>
+<tbody>
+<tr>
+<td class="align-left">1</td>
+<td class="align-right">2</td>
+</tr>
+</tbody>
+</table>
+`, convert(table, TableOptions{Sections: true, Alignment: ClassAlignment}))
+	})
+	t.Run("columns", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<table>
+<caption>Sizes</caption>
+<colgroup>
+<col class="align-left">
+<col class="align-right">
+</colgroup>
+<tbody><tr>
+<th>a</th>
+<th>b</th>
+</tr>
+<tr>
+<td>1</td>
+<td>2</td>
+</tr>
+</tbody></table>
+`, convert(table, TableOptions{Alignment: ColumnAlignment}))
+	})
+	t.Run("row and cell attributes", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<table>
+<tr class="total">
+<td>Total</td>
+<td class="align-right num" id="sum">42 <em>kg</em></td>
+<td class="empty"></td>
+</tr>
+</table>
+`, convert("|---|--:|---|\n| Total | 42 _kg_ {#sum .num} | {.empty} |{.total}\n", TableOptions{Alignment: ClassAlignment}))
+	})
+}
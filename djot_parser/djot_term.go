@@ -0,0 +1,721 @@
+package djot_parser
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"md0.org/djot/djot_tokenizer"
+)
+
+const (
+	TermFormat       = "term"
+	DefaultTermWidth = 80
+	minTermWidth     = 10
+)
+
+// SGR parameters used by the terminal renderer
+const (
+	termBold          = "1"
+	termDim           = "2"
+	termItalic        = "3"
+	termUnderline     = "4"
+	termReverse       = "7"
+	termStrikethrough = "9"
+	termCode          = "36"
+	termLink          = "4;34"
+	termCodeBlock     = "48;5;236"
+)
+
+var termHeadingStyles = []string{"1;4;35", "1;35", "1;34", "1;36"}
+
+type TermOptions struct {
+	Width       int         // maximum line width, DefaultTermWidth if not positive
+	NoColor     bool        // disable all escape sequences (colors, styles and hyperlinks)
+	Admonitions Admonitions // divs with these classes are rendered as callouts with a title
+}
+
+type termSpan struct {
+	Text      string
+	Style     string
+	Link      string
+	LineBreak bool
+	Raw       bool // text of =term raw inline is written as is, other text is stripped of control characters
+}
+
+type termCell struct {
+	Spans     []termSpan
+	Alignment string
+	Header    bool
+}
+
+type termRenderer struct{ options TermOptions }
+
+// ConvertDjotToTerm renders AST as text for ANSI terminal (e.g. for the `less -R` pager)
+func ConvertDjotToTerm(options TermOptions, nodes ...TreeNode[DjotNode]) string {
+	if options.Width <= 0 {
+		options.Width = DefaultTermWidth
+	}
+	r := termRenderer{options: options}
+	lines := r.blocks(nodes, options.Width, false)
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (r termRenderer) blocks(nodes []TreeNode[DjotNode], width int, tight bool) []string {
+	lines := make([]string, 0)
+	separate := func(block []string) {
+		if len(block) == 0 {
+			return
+		}
+		if len(lines) > 0 && !tight {
+			lines = append(lines, "")
+		}
+		lines = append(lines, block...)
+	}
+	for i := 0; i < len(nodes); i++ {
+		if nodes[i].Type.IsInline() {
+			start := i
+			for i+1 < len(nodes) && nodes[i+1].Type.IsInline() {
+				i++
+			}
+			separate(r.wrap(r.inlines(nodes[start:i+1], "", ""), width))
+			continue
+		}
+		separate(r.block(nodes[i], width))
+	}
+	return lines
+}
+
+func (r termRenderer) block(node TreeNode[DjotNode], width int) []string {
+	switch node.Type {
+	case DocumentNode, SectionNode, DivNode, FootnoteDefNode:
+		if admonition, ok := r.options.Admonitions.Find(node); ok {
+			return r.admonition(node, admonition, width)
+		}
+		return r.blocks(node.Children, width, false)
+	case ParagraphNode:
+		return r.wrap(r.inlines(node.Children, "", ""), width)
+	case HeadingNode:
+		level := len(node.Attributes.Get(HeadingLevelKey))
+		style := termHeadingStyles[min(max(level, 1), len(termHeadingStyles))-1]
+		spans := r.inlines(node.Children, style, "")
+		if r.options.NoColor {
+			spans = append([]termSpan{{Text: strings.Repeat("#", level) + " "}}, spans...)
+		}
+		return r.wrap(spans, width)
+	case QuoteNode:
+		border := r.paint("│", termDim) + " "
+		return indent(r.blocks(node.Children, width-2, false), border, border)
+	case UnorderedListNode, OrderedListNode, TaskListNode:
+		return r.list(node, width)
+	case DefinitionListNode:
+		_, sparse := node.Attributes.TryGet(SparseListNodeKey)
+		lines := make([]string, 0)
+		for _, child := range node.Children {
+			switch child.Type {
+			case DefinitionTermNode:
+				if sparse && len(lines) > 0 {
+					lines = append(lines, "")
+				}
+				lines = append(lines, r.wrap(r.inlines(child.Children, termBold, ""), width)...)
+			case DefinitionItemNode:
+				lines = append(lines, indent(r.blocks(child.Children, width-4, !sparse), "    ", "    ")...)
+			}
+		}
+		return lines
+	case CodeNode:
+		return r.code(string(node.FullText()), width)
+	case RawNode:
+		if node.Attributes.Get(RawBlockFormatKey) != TermFormat {
+			return nil
+		}
+		return strings.Split(strings.TrimSuffix(string(node.FullText()), "\n"), "\n")
+	case ThematicBreakNode:
+		return []string{r.paint(strings.Repeat("─", max(width, minTermWidth)), termDim)}
+	case TableNode:
+		return r.table(node, width)
+	case FigureNode:
+		lines := make([]string, 0)
+		for _, child := range node.Children {
+			if child.Type != FigureCaptionNode {
+				lines = append(lines, r.blocks([]TreeNode[DjotNode]{child}, width, false)...)
+				continue
+			}
+			spans := r.inlines(child.Children, termItalic, "")
+			if label := node.Attributes.Get(CrossReferenceKey); label != "" {
+				spans = append([]termSpan{{Text: label + ": ", Style: termBold}}, spans...)
+			}
+			lines = append(lines, r.wrap(spans, width)...)
+		}
+		return lines
+	}
+	return nil
+}
+
+func (r termRenderer) admonition(node TreeNode[DjotNode], admonition Admonition, width int) []string {
+	title, body := admonitionParts(node, admonition)
+	spans := r.inlines(title, termBold, "")
+	if admonition.Icon != "" {
+		spans = append([]termSpan{{Text: admonition.Icon + " ", Style: termBold}}, spans...)
+	}
+	border := r.paint("┃", termBold) + " "
+	lines := append(r.wrap(spans, width-2), r.blocks(body, width-2, false)...)
+	return indent(lines, border, border)
+}
+
+func (r termRenderer) list(node TreeNode[DjotNode], width int) []string {
+	_, sparse := node.Attributes.TryGet(SparseListNodeKey)
+	start := 1
+	if value, err := strconv.Atoi(node.Attributes.Get("start")); err == nil {
+		start = value
+	}
+	markers := make([]string, len(node.Children))
+	markerWidth := 0
+	for i, item := range node.Children {
+		switch node.Type {
+		case UnorderedListNode:
+			markers[i] = "•"
+		case TaskListNode:
+			if item.Attributes.Get(djot_tokenizer.DjotAttributeClassKey) == CheckedTaskItemClass {
+				markers[i] = "☑"
+			} else {
+				markers[i] = "☐"
+			}
+		case OrderedListNode:
+			markers[i] = orderedListMarker(node.Attributes.Get("type"), start+i) + "."
+		}
+		markerWidth = max(markerWidth, visibleWidth(markers[i]))
+	}
+	lines := make([]string, 0)
+	for i, item := range node.Children {
+		body := r.blocks(item.Children, width-markerWidth-1, !sparse)
+		if len(body) == 0 {
+			body = []string{""}
+		}
+		if sparse && i > 0 {
+			lines = append(lines, "")
+		}
+		marker := markers[i] + strings.Repeat(" ", markerWidth-visibleWidth(markers[i])+1)
+		lines = append(lines, indent(body, marker, strings.Repeat(" ", markerWidth+1))...)
+	}
+	return lines
+}
+
+func orderedListMarker(markerType string, number int) string {
+	if number < 1 || number > 26 {
+		return strconv.Itoa(number)
+	}
+	switch markerType {
+	case "a":
+		return string(rune('a' + number - 1))
+	case "A":
+		return string(rune('A' + number - 1))
+	}
+	return strconv.Itoa(number)
+}
+
+func (r termRenderer) code(text string, width int) []string {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSuffix(text, "\n"), "\t", "    "), "\n")
+	for i, line := range lines {
+		lines[i] = termSanitize(line)
+	}
+	if r.options.NoColor {
+		return indent(lines, "    ", "    ")
+	}
+	for i, line := range lines {
+		padding := max(width-2-visibleWidth(line), 0)
+		lines[i] = r.paint(" "+line+strings.Repeat(" ", padding)+" ", termCodeBlock)
+	}
+	return lines
+}
+
+// table draws the table with box characters, columns are shrunk to fit into the width and their cells are wrapped
+func (r termRenderer) table(node TreeNode[DjotNode], width int) []string {
+	var caption []termSpan
+	var rest []TreeNode[DjotNode]
+	rows := make([][]termCell, 0)
+	columns := 0
+	for _, child := range node.Children {
+		switch child.Type {
+		case TableCaptionNode:
+			caption = r.inlines(child.Children, termItalic, "")
+		case TableRowNode:
+			row := make([]termCell, 0, len(child.Children))
+			for _, cell := range child.Children {
+				header := cell.Type == TableHeaderNode
+				style := ""
+				if header {
+					style = termBold
+				}
+				row = append(row, termCell{
+					Spans:     r.inlines(cell.Children, style, ""),
+					Alignment: cell.Attributes.Get(TableAlignmentKey),
+					Header:    header,
+				})
+			}
+			rows = append(rows, row)
+			columns = max(columns, len(row))
+		default:
+			rest = append(rest, child)
+		}
+	}
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for i, cell := range row {
+			for _, line := range r.fill(cell.Spans, -1, false) {
+				widths[i] = max(widths[i], visibleWidth(line))
+			}
+		}
+	}
+	fitTermColumns(widths, width)
+	border := func(left, middle, right string) string {
+		parts := make([]string, columns)
+		for i, w := range widths {
+			parts[i] = strings.Repeat("─", w+2)
+		}
+		return left + strings.Join(parts, middle) + right
+	}
+	lines := []string{border("┌", "┬", "┐")}
+	for k, row := range rows {
+		cells, height := make([][]string, columns), 1
+		for i := range row {
+			cells[i] = r.fill(row[i].Spans, widths[i], true)
+			height = max(height, len(cells[i]))
+		}
+		for line := 0; line < height; line++ {
+			parts := make([]string, columns)
+			for i := range widths {
+				text, alignment := "", DefaultAlignment
+				if line < len(cells[i]) {
+					text = cells[i][line]
+				}
+				if i < len(row) {
+					alignment = row[i].Alignment
+				}
+				padding := widths[i] - visibleWidth(text)
+				switch alignment {
+				case RightAlignment:
+					parts[i] = strings.Repeat(" ", padding) + text
+				case CenterAlignment:
+					parts[i] = strings.Repeat(" ", padding/2) + text + strings.Repeat(" ", padding-padding/2)
+				default:
+					parts[i] = text + strings.Repeat(" ", padding)
+				}
+			}
+			lines = append(lines, "│ "+strings.Join(parts, " │ ")+" │")
+		}
+		if k+1 < len(rows) && isTermHeaderRow(row) && !isTermHeaderRow(rows[k+1]) {
+			lines = append(lines, border("├", "┼", "┤"))
+		}
+	}
+	lines = append(lines, border("└", "┴", "┘"))
+	if len(caption) > 0 {
+		lines = append(lines, r.wrap(caption, width)...)
+	}
+	if len(rest) > 0 {
+		lines = append(append(lines, ""), r.blocks(rest, width, false)...)
+	}
+	return lines
+}
+
+// fitTermColumns narrows the widest columns one cell at a time until the table with its borders fits into the width,
+// every column keeps at least one cell
+func fitTermColumns(widths []int, width int) {
+	total := 3*len(widths) + 1
+	for _, w := range widths {
+		total += w
+	}
+	for total > width {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if len(widths) == 0 || widths[widest] <= 1 {
+			return
+		}
+		widths[widest]--
+		total--
+	}
+}
+
+func isTermHeaderRow(row []termCell) bool {
+	for _, cell := range row {
+		if !cell.Header {
+			return false
+		}
+	}
+	return len(row) > 0
+}
+
+func joinTermStyle(style, other string) string {
+	if style == "" {
+		return other
+	}
+	return style + ";" + other
+}
+
+func (r termRenderer) inlines(nodes []TreeNode[DjotNode], style, link string) []termSpan {
+	spans := make([]termSpan, 0)
+	for _, node := range nodes {
+		switch node.Type {
+		case TextNode:
+			spans = append(spans, termSpan{Text: string(node.Text), Style: style, Link: link})
+		case LineBreakNode:
+			spans = append(spans, termSpan{LineBreak: true})
+		case EmphasisNode:
+			spans = append(spans, r.inlines(node.Children, joinTermStyle(style, termItalic), link)...)
+		case StrongNode:
+			spans = append(spans, r.inlines(node.Children, joinTermStyle(style, termBold), link)...)
+		case HighlightedNode:
+			spans = append(spans, r.inlines(node.Children, joinTermStyle(style, termReverse), link)...)
+		case InsertNode:
+			spans = append(spans, r.inlines(node.Children, joinTermStyle(style, termUnderline), link)...)
+		case DeleteNode:
+			spans = append(spans, r.inlines(node.Children, joinTermStyle(style, termStrikethrough), link)...)
+		case SubscriptNode, SuperscriptNode, SpanNode:
+			spans = append(spans, r.inlines(node.Children, style, link)...)
+		case SymbolsNode:
+			spans = append(spans, termSpan{Text: ":" + string(node.FullText()) + ":", Style: style, Link: link})
+		case VerbatimNode:
+			text := string(node.FullText())
+			if rawFormat, ok := node.Attributes.TryGet(RawInlineFormatKey); ok {
+				if rawFormat == TermFormat {
+					spans = append(spans, termSpan{Text: text, Raw: true})
+				}
+				continue
+			}
+			_, inlineMath := node.Attributes.TryGet(djot_tokenizer.InlineMathKey)
+			_, displayMath := node.Attributes.TryGet(djot_tokenizer.DisplayMathKey)
+			if r.options.NoColor && !inlineMath && !displayMath {
+				text = "`" + text + "`"
+			}
+			spans = append(spans, termSpan{Text: text, Style: joinTermStyle(style, termCode), Link: link})
+		case LinkNode:
+			href := node.Attributes.Get(LinkHrefKey)
+			switch node.Attributes.Get(RoleKey) {
+			case "doc-backlink":
+				continue
+			case "doc-noteref":
+				spans = append(spans, termSpan{Text: "[" + string(node.FullText()) + "]", Style: joinTermStyle(style, termDim), Link: link})
+				continue
+			}
+			if href == "" {
+				spans = append(spans, r.inlines(node.Children, style, link)...)
+			} else if r.options.NoColor {
+				spans = append(spans, r.inlines(node.Children, style, link)...)
+				if href != string(node.FullText()) {
+					spans = append(spans, termSpan{Text: " <" + href + ">"})
+				}
+			} else {
+				spans = append(spans, r.inlines(node.Children, joinTermStyle(style, termLink), href)...)
+			}
+		case ImageNode:
+			text := "[image: " + node.Attributes.Get(ImgAltKey) + "]"
+			src := node.Attributes.Get(ImgSrcKey)
+			if r.options.NoColor && src != "" {
+				spans = append(spans, termSpan{Text: text + " <" + src + ">"})
+			} else {
+				spans = append(spans, termSpan{Text: text, Style: joinTermStyle(style, termLink), Link: src})
+			}
+		}
+	}
+	return spans
+}
+
+// wrap fills lines with words from spans, lines are unlimited if width is negative
+func (r termRenderer) wrap(spans []termSpan, width int) []string {
+	if width >= 0 {
+		width = max(width, minTermWidth)
+	}
+	return r.fill(spans, width, false)
+}
+
+// fill is wrap without the minimal width, words wider than the width are broken into several lines if breakWords
+// is set (table cells can't overflow their columns)
+func (r termRenderer) fill(spans []termSpan, width int, breakWords bool) []string {
+	lines := make([]string, 0)
+	var (
+		line      strings.Builder
+		lineWidth = 0
+		word      []termSpan
+		wordWidth = 0
+	)
+	flushLine := func() {
+		lines = append(lines, line.String())
+		line.Reset()
+		lineWidth = 0
+	}
+	place := func(word []termSpan, wordWidth int) {
+		if lineWidth > 0 && width >= 0 && lineWidth+1+wordWidth > width {
+			flushLine()
+		}
+		if lineWidth > 0 {
+			line.WriteString(" ")
+			lineWidth++
+		}
+		for _, span := range word {
+			line.WriteString(r.render(span))
+		}
+		lineWidth += wordWidth
+	}
+	flushWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		for breakWords && width > 0 && wordWidth > width {
+			head, headWidth, tail := splitTermSpans(word, width)
+			place(head, headWidth)
+			word, wordWidth = tail, wordWidth-headWidth
+		}
+		place(word, wordWidth)
+		word, wordWidth = nil, 0
+	}
+	appendWord := func(span termSpan, text string) {
+		span.Text = text
+		word = append(word, span)
+		wordWidth += visibleWidth(text)
+	}
+	for _, span := range spans {
+		if span.LineBreak {
+			flushWord()
+			flushLine()
+			continue
+		}
+		start := 0
+		for i, c := range span.Text {
+			// no-break spaces are part of the word
+			if !unicode.IsSpace(c) || c == '\u00a0' || c == '\u202f' {
+				continue
+			}
+			if start < i {
+				appendWord(span, span.Text[start:i])
+			}
+			flushWord()
+			start = i + utf8.RuneLen(c)
+		}
+		if start < len(span.Text) {
+			appendWord(span, span.Text[start:])
+		}
+	}
+	flushWord()
+	if lineWidth > 0 {
+		flushLine()
+	}
+	return lines
+}
+
+// splitTermSpans cuts the word after the last rune which fits into the width (at least one rune is always taken)
+func splitTermSpans(spans []termSpan, width int) ([]termSpan, int, []termSpan) {
+	head, headWidth := make([]termSpan, 0, len(spans)), 0
+	for i, span := range spans {
+		for j, c := range span.Text {
+			if w := runeWidth(c); headWidth+w <= width || headWidth == 0 {
+				headWidth += w
+				continue
+			}
+			if j > 0 {
+				piece := span
+				piece.Text = span.Text[:j]
+				head = append(head, piece)
+			}
+			span.Text = span.Text[j:]
+			return head, headWidth, append([]termSpan{span}, spans[i+1:]...)
+		}
+		head = append(head, span)
+	}
+	return head, headWidth, nil
+}
+
+func (r termRenderer) render(span termSpan) string {
+	text := span.Text
+	if !span.Raw {
+		text = termSanitize(text)
+	}
+	if r.options.NoColor {
+		return text
+	}
+	text = r.paint(text, span.Style)
+	if span.Link != "" {
+		text = "\x1b]8;;" + termSanitize(span.Link) + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+	}
+	return text
+}
+
+// termSanitize drops control characters (like ESC or BEL), so the document can't write its own escape sequences or
+// terminate the hyperlink sequence
+func termSanitize(text string) string {
+	return strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) {
+			return -1
+		}
+		return c
+	}, text)
+}
+
+func (r termRenderer) paint(text, style string) string {
+	if r.options.NoColor || style == "" {
+		return text
+	}
+	return "\x1b[" + style + "m" + text + "\x1b[0m"
+}
+
+func indent(lines []string, first, rest string) []string {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if line == "" {
+			prefix = strings.TrimRight(prefix, " ")
+		}
+		result[i] = prefix + line
+	}
+	return result
+}
+
+// visibleWidth counts terminal cells taken by the text skipping CSI and OSC escape sequences
+func visibleWidth(text string) int {
+	width := 0
+	for i := 0; i < len(text); {
+		if text[i] == '\x1b' && i+1 < len(text) && text[i+1] == '[' {
+			i += 2
+			for i < len(text) && (text[i] < 0x40 || text[i] > 0x7e) {
+				i++
+			}
+			i++
+			continue
+		}
+		if text[i] == '\x1b' && i+1 < len(text) && text[i+1] == ']' {
+			end := strings.Index(text[i:], "\x1b\\")
+			if end == -1 {
+				return width
+			}
+			i += end + 2
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(text[i:])
+		width += runeWidth(c)
+		i += size
+	}
+	return width
+}
+
+// runeWidth is the number of terminal cells taken by the rune: combining marks and control characters take none,
+// East Asian wide characters and emoji take two
+func runeWidth(c rune) int {
+	switch {
+	case unicode.IsControl(c) || unicode.In(c, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case unicode.Is(termWideRunes, c):
+		return 2
+	}
+	return 1
+}
+
+// termWideRunes are characters with East Asian width W or F (CJK, Hangul, fullwidth forms) and emoji with default
+// emoji presentation
+var termWideRunes = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x1100, Hi: 0x115f, Stride: 1},
+		{Lo: 0x231a, Hi: 0x231b, Stride: 1},
+		{Lo: 0x2329, Hi: 0x232a, Stride: 1},
+		{Lo: 0x23e9, Hi: 0x23ec, Stride: 1},
+		{Lo: 0x23f0, Hi: 0x23f0, Stride: 1},
+		{Lo: 0x23f3, Hi: 0x23f3, Stride: 1},
+		{Lo: 0x25fd, Hi: 0x25fe, Stride: 1},
+		{Lo: 0x2614, Hi: 0x2615, Stride: 1},
+		{Lo: 0x2648, Hi: 0x2653, Stride: 1},
+		{Lo: 0x267f, Hi: 0x267f, Stride: 1},
+		{Lo: 0x2693, Hi: 0x2693, Stride: 1},
+		{Lo: 0x26a1, Hi: 0x26a1, Stride: 1},
+		{Lo: 0x26aa, Hi: 0x26ab, Stride: 1},
+		{Lo: 0x26bd, Hi: 0x26be, Stride: 1},
+		{Lo: 0x26c4, Hi: 0x26c5, Stride: 1},
+		{Lo: 0x26ce, Hi: 0x26ce, Stride: 1},
+		{Lo: 0x26d4, Hi: 0x26d4, Stride: 1},
+		{Lo: 0x26ea, Hi: 0x26ea, Stride: 1},
+		{Lo: 0x26f2, Hi: 0x26f3, Stride: 1},
+		{Lo: 0x26f5, Hi: 0x26f5, Stride: 1},
+		{Lo: 0x26fa, Hi: 0x26fa, Stride: 1},
+		{Lo: 0x26fd, Hi: 0x26fd, Stride: 1},
+		{Lo: 0x2705, Hi: 0x2705, Stride: 1},
+		{Lo: 0x270a, Hi: 0x270b, Stride: 1},
+		{Lo: 0x2728, Hi: 0x2728, Stride: 1},
+		{Lo: 0x274c, Hi: 0x274c, Stride: 1},
+		{Lo: 0x274e, Hi: 0x274e, Stride: 1},
+		{Lo: 0x2753, Hi: 0x2755, Stride: 1},
+		{Lo: 0x2757, Hi: 0x2757, Stride: 1},
+		{Lo: 0x2795, Hi: 0x2797, Stride: 1},
+		{Lo: 0x27b0, Hi: 0x27b0, Stride: 1},
+		{Lo: 0x27bf, Hi: 0x27bf, Stride: 1},
+		{Lo: 0x2b1b, Hi: 0x2b1c, Stride: 1},
+		{Lo: 0x2b50, Hi: 0x2b50, Stride: 1},
+		{Lo: 0x2b55, Hi: 0x2b55, Stride: 1},
+		{Lo: 0x2e80, Hi: 0x303e, Stride: 1},
+		{Lo: 0x3041, Hi: 0x33ff, Stride: 1},
+		{Lo: 0x3400, Hi: 0x4dbf, Stride: 1},
+		{Lo: 0x4e00, Hi: 0x9fff, Stride: 1},
+		{Lo: 0xa000, Hi: 0xa4cf, Stride: 1},
+		{Lo: 0xa960, Hi: 0xa97f, Stride: 1},
+		{Lo: 0xac00, Hi: 0xd7a3, Stride: 1},
+		{Lo: 0xf900, Hi: 0xfaff, Stride: 1},
+		{Lo: 0xfe10, Hi: 0xfe19, Stride: 1},
+		{Lo: 0xfe30, Hi: 0xfe6f, Stride: 1},
+		{Lo: 0xff00, Hi: 0xff60, Stride: 1},
+		{Lo: 0xffe0, Hi: 0xffe6, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x16fe0, Hi: 0x16fe4, Stride: 1},
+		{Lo: 0x17000, Hi: 0x18cff, Stride: 1},
+		{Lo: 0x1b000, Hi: 0x1b2ff, Stride: 1},
+		{Lo: 0x1f004, Hi: 0x1f004, Stride: 1},
+		{Lo: 0x1f0cf, Hi: 0x1f0cf, Stride: 1},
+		{Lo: 0x1f18e, Hi: 0x1f18e, Stride: 1},
+		{Lo: 0x1f191, Hi: 0x1f19a, Stride: 1},
+		{Lo: 0x1f200, Hi: 0x1f202, Stride: 1},
+		{Lo: 0x1f210, Hi: 0x1f23b, Stride: 1},
+		{Lo: 0x1f240, Hi: 0x1f248, Stride: 1},
+		{Lo: 0x1f250, Hi: 0x1f251, Stride: 1},
+		{Lo: 0x1f260, Hi: 0x1f265, Stride: 1},
+		{Lo: 0x1f300, Hi: 0x1f320, Stride: 1},
+		{Lo: 0x1f32d, Hi: 0x1f335, Stride: 1},
+		{Lo: 0x1f337, Hi: 0x1f37c, Stride: 1},
+		{Lo: 0x1f37e, Hi: 0x1f393, Stride: 1},
+		{Lo: 0x1f3a0, Hi: 0x1f3ca, Stride: 1},
+		{Lo: 0x1f3cf, Hi: 0x1f3d3, Stride: 1},
+		{Lo: 0x1f3e0, Hi: 0x1f3f0, Stride: 1},
+		{Lo: 0x1f3f4, Hi: 0x1f3f4, Stride: 1},
+		{Lo: 0x1f3f8, Hi: 0x1f43e, Stride: 1},
+		{Lo: 0x1f440, Hi: 0x1f440, Stride: 1},
+		{Lo: 0x1f442, Hi: 0x1f4fc, Stride: 1},
+		{Lo: 0x1f4ff, Hi: 0x1f53d, Stride: 1},
+		{Lo: 0x1f54b, Hi: 0x1f54e, Stride: 1},
+		{Lo: 0x1f550, Hi: 0x1f567, Stride: 1},
+		{Lo: 0x1f57a, Hi: 0x1f57a, Stride: 1},
+		{Lo: 0x1f595, Hi: 0x1f596, Stride: 1},
+		{Lo: 0x1f5a4, Hi: 0x1f5a4, Stride: 1},
+		{Lo: 0x1f5fb, Hi: 0x1f64f, Stride: 1},
+		{Lo: 0x1f680, Hi: 0x1f6c5, Stride: 1},
+		{Lo: 0x1f6cc, Hi: 0x1f6cc, Stride: 1},
+		{Lo: 0x1f6d0, Hi: 0x1f6d2, Stride: 1},
+		{Lo: 0x1f6d5, Hi: 0x1f6d7, Stride: 1},
+		{Lo: 0x1f6dc, Hi: 0x1f6df, Stride: 1},
+		{Lo: 0x1f6eb, Hi: 0x1f6ec, Stride: 1},
+		{Lo: 0x1f6f4, Hi: 0x1f6fc, Stride: 1},
+		{Lo: 0x1f7e0, Hi: 0x1f7eb, Stride: 1},
+		{Lo: 0x1f7f0, Hi: 0x1f7f0, Stride: 1},
+		{Lo: 0x1f90c, Hi: 0x1f93a, Stride: 1},
+		{Lo: 0x1f93c, Hi: 0x1f945, Stride: 1},
+		{Lo: 0x1f947, Hi: 0x1f9ff, Stride: 1},
+		{Lo: 0x1fa70, Hi: 0x1faff, Stride: 1},
+		{Lo: 0x20000, Hi: 0x2fffd, Stride: 1},
+		{Lo: 0x30000, Hi: 0x3fffd, Stride: 1},
+	},
+}
@@ -0,0 +1,81 @@
+package djot_parser
+
+import (
+	"testing"
+
+	"md0.org/djot/internal/testx"
+	"md0.org/djot/tokenizer"
+)
+
+func printTerm(text string, options TermOptions) string {
+	return ConvertDjotToTerm(options, BuildDjotAst([]byte(text))...)
+}
+
+func TestConvertDjotToTerm(t *testing.T) {
+	noColor := TermOptions{Width: 20, NoColor: true}
+	t.Run("heading and wrapping", func(t *testing.T) {
+		result := printTerm("## Title\n\nThe quick brown fox jumps over the lazy dog", noColor)
+		testx.AssertEqual(t, "", "## Title\n\nThe quick brown fox\njumps over the lazy\ndog\n", result)
+	})
+	t.Run("link without color", func(t *testing.T) {
+		result := printTerm("[site](https://example.com)", noColor)
+		testx.AssertEqual(t, "", "site\n<https://example.com>\n", result)
+	})
+	t.Run("link as hyperlink", func(t *testing.T) {
+		result := printTerm("[site](https://example.com)", TermOptions{})
+		testx.AssertEqual(t, "", "\x1b]8;;https://example.com\x1b\\\x1b[4;34msite\x1b[0m\x1b]8;;\x1b\\\n", result)
+	})
+	t.Run("emphasis", func(t *testing.T) {
+		result := printTerm("_a_ *b*", TermOptions{})
+		testx.AssertEqual(t, "", "\x1b[3ma\x1b[0m \x1b[1mb\x1b[0m\n", result)
+	})
+	t.Run("lists and quotes", func(t *testing.T) {
+		result := printTerm("> - one\n> - two\n\n- [x] done", noColor)
+		testx.AssertEqual(t, "", "│ • one\n│ • two\n\n☑ done\n", result)
+	})
+	t.Run("admonition", func(t *testing.T) {
+		options := TermOptions{Width: 20, NoColor: true, Admonitions: DefaultAdmonitions}
+		result := printTerm("::: danger\nThe quick brown fox jumps\n:::\n\n::: other\nx\n:::", options)
+		testx.AssertEqual(t, "", "┃ ⛔ Danger\n┃ The quick brown\n┃ fox jumps\n\nx\n", result)
+	})
+	t.Run("code block", func(t *testing.T) {
+		result := printTerm("```\nx := 1\n```", TermOptions{Width: 12})
+		testx.AssertEqual(t, "", "\x1b[48;5;236m x := 1     \x1b[0m\n", result)
+	})
+	t.Run("table", func(t *testing.T) {
+		result := printTerm("| a | bb |\n|---|---:|\n| ccc | d |", noColor)
+		testx.AssertEqual(t, "", "┌─────┬────┐\n│ a   │ bb │\n├─────┼────┤\n│ ccc │  d │\n└─────┴────┘\n", result)
+	})
+	t.Run("visible width", func(t *testing.T) {
+		testx.AssertEqual(t, "", 4, visibleWidth("\x1b]8;;https://a\x1b\\\x1b[1mlink\x1b[0m\x1b]8;;\x1b\\"))
+		testx.AssertEqual(t, "", 8, visibleWidth("日本語 e\u0301"))
+		testx.AssertEqual(t, "", 2, visibleWidth("💡"))
+	})
+	t.Run("control characters", func(t *testing.T) {
+		link := TreeNode[DjotNode]{
+			Type:       LinkNode,
+			Attributes: tokenizer.NewAttributes(tokenizer.AttributeEntry{Key: LinkHrefKey, Value: "https://x\x07\x1b]8;;evil"}),
+			Children:   []TreeNode[DjotNode]{{Type: TextNode, Text: []byte("a\x1b[2Jb")}},
+		}
+		result := ConvertDjotToTerm(TermOptions{}, TreeNode[DjotNode]{Type: ParagraphNode, Children: []TreeNode[DjotNode]{link}})
+		testx.AssertEqual(t, "", "\x1b]8;;https://x]8;;evil\x1b\\\x1b[4;34ma[2Jb\x1b[0m\x1b]8;;\x1b\\\n", result)
+		testx.AssertEqual(t, "", "    x[31m\n", printTerm("```\nx\x1b[31m\n```", noColor))
+	})
+	t.Run("wide characters", func(t *testing.T) {
+		result := printTerm("| 日本 | a |\n| b | c |", noColor)
+		testx.AssertEqual(t, "", "┌──────┬───┐\n│ 日本 │ a │\n│ b    │ c │\n└──────┴───┘\n", result)
+	})
+	t.Run("table width", func(t *testing.T) {
+		result := printTerm("| The quick brown fox | jumps |\n| a | b |", TermOptions{Width: 20, NoColor: true})
+		testx.AssertEqual(t, "", `┌──────────┬───────┐
+│ The      │ jumps │
+│ quick    │       │
+│ brown    │       │
+│ fox      │       │
+│ a        │ b     │
+└──────────┴───────┘
+`, result)
+		result = printTerm("| abcdefgh | b |", TermOptions{Width: 12, NoColor: true})
+		testx.AssertEqual(t, "", "┌──────┬───┐\n│ abcd │ b │\n│ efgh │   │\n└──────┴───┘\n", result)
+	})
+}
@@ -1,12 +1,19 @@
 package djot_parser
 
-import "md0.org/djot/tokenizer"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"md0.org/djot/tokenizer"
+)
 
 type TreeNode[T ~int] struct {
 	Type       T
 	Attributes tokenizer.Attributes
 	Children   []TreeNode[T]
 	Text       []byte
+	Range      tokenizer.Range // byte range of the node in its source document, empty for generated nodes
 }
 
 func (n TreeNode[T]) Traverse(f func(node TreeNode[T])) {
@@ -32,3 +39,36 @@ func (n TreeNode[T]) FullText() []byte {
 	})
 	return text
 }
+
+// String writes the tree one node per line indented by its depth: node type, attributes (including internal ones)
+// and quoted text, so the tree can be printed with %v while debugging
+func (n TreeNode[T]) String() string {
+	var builder strings.Builder
+	n.dump(&builder, 0, false)
+	return builder.String()
+}
+
+// StringWithRanges writes the tree like String with the source range after the type of every node which has it
+func (n TreeNode[T]) StringWithRanges() string {
+	var builder strings.Builder
+	n.dump(&builder, 0, true)
+	return builder.String()
+}
+
+func (n TreeNode[T]) dump(builder *strings.Builder, depth int, ranges bool) {
+	builder.WriteString(strings.Repeat("  ", depth))
+	builder.WriteString(fmt.Sprint(n.Type))
+	if ranges && n.Range != (tokenizer.Range{}) {
+		builder.WriteString(fmt.Sprintf(" %v-%v", n.Range.Start, n.Range.End))
+	}
+	for _, entry := range n.Attributes.Entries() {
+		builder.WriteString(" " + entry.Key + "=" + strconv.Quote(entry.Value))
+	}
+	if n.Text != nil {
+		builder.WriteString(" " + strconv.Quote(string(n.Text)))
+	}
+	builder.WriteString("\n")
+	for _, child := range n.Children {
+		child.dump(builder, depth+1, ranges)
+	}
+}
@@ -179,6 +179,12 @@ func MatchBlockToken(
 		for last > next && r.HasMask(last, tokenizer.SpaceNewLineByteMask) {
 			last--
 		}
+		// row can end with attributes of the row right after the last separator
+		if open := bytes.LastIndex(r[next:last], []byte("|{")); r[last] == '}' && open >= 0 {
+			if _, end, ok := MatchDjotAttribute(r, next+open+1); ok && r.IsEmptyOrWhiteSpace(end) {
+				last = next + open
+			}
+		}
 		if r[last] != '|' {
 			return fail()
 		}
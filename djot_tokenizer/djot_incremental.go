@@ -0,0 +1,120 @@
+package djot_tokenizer
+
+import (
+	"bytes"
+
+	"md0.org/djot/tokenizer"
+)
+
+// Edit replaces bytes [Start, End) of the document with Text
+type Edit struct {
+	Start, End int
+	Text       []byte
+}
+
+// Apply returns new document with the edit applied
+func (e Edit) Apply(document []byte) []byte {
+	result := make([]byte, 0, len(document)-(e.End-e.Start)+len(e.Text))
+	result = append(result, document[:e.Start]...)
+	result = append(result, e.Text...)
+	return append(result, document[e.End:]...)
+}
+
+// cut is a line start between top level blocks where tokenizer has no open blocks except the DocumentBlock
+type cut struct {
+	position int // line start in the document
+	index    int // index of the first top level token at or after position
+}
+
+// topLevelCuts lists cuts of the tokenized document in increasing order, document start and end are always cuts;
+// position is a cut only if previous block was closed strictly before it (so block wasn't closed by the line itself)
+// and it doesn't separate pipe table from its caption
+func topLevelCuts(document []byte, list tokenizer.TokenList[DjotToken]) []cut {
+	cuts := []cut{{position: list[0].Start, index: 1}}
+	previousEnd, previousType := -1, DjotToken(DocumentBlock)
+	for i := 1; i < len(list)-1; i += max(list[i].JumpToPair, 0) + 1 {
+		token := list[i]
+		lineStart := bytes.LastIndexByte(document[:token.Start], '\n') + 1
+		if previousEnd >= 0 && previousEnd < lineStart && previousType != PipeTableBlock && token.Type != PipeTableCaptionBlock {
+			cuts = append(cuts, cut{position: lineStart, index: i})
+		}
+		previousEnd, previousType = list[i+max(token.JumpToPair, 0)].End, token.Type
+	}
+	return append(cuts, cut{position: len(document), index: len(list) - 1})
+}
+
+// tokenizeRegion tokenizes document from the start position, tests replace it to count tokenized bytes
+var tokenizeRegion = buildDjotTokens
+
+func hasDefinitions(list tokenizer.TokenList[DjotToken]) bool {
+	for _, token := range list {
+		if token.Type == ReferenceDefBlock || token.Type == FootnoteDefBlock {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateDjotTokens applies edit to the document and returns new document with its tokens; tokens of top level blocks
+// untouched by the edit are reused from the list (which must be result of the BuildDjotTokens for the document) and
+// only affected blocks are tokenized again. Document is tokenized from scratch if edit changes reference or footnote
+// definitions because they affect the whole document.
+func UpdateDjotTokens(document []byte, list tokenizer.TokenList[DjotToken], edit Edit) ([]byte, tokenizer.TokenList[DjotToken]) {
+	updated := edit.Apply(document)
+	delta := len(edit.Text) - (edit.End - edit.Start)
+	cuts := topLevelCuts(document, list)
+
+	first := 0
+	for first+2 < len(cuts) && cuts[first+1].position <= edit.Start {
+		first++
+	}
+	start, last := cuts[first], first+1
+	for last+1 < len(cuts) && (cuts[last].position < edit.End || cuts[last].position+delta > 0 && updated[cuts[last].position+delta-1] != '\n') {
+		last++
+	}
+	end := cuts[last]
+	// usually the edit affects only blocks up to the next cut, otherwise (e.g. an unclosed code block was inserted)
+	// rest of the document is tokenized once and the first cut common to both documents ends the region
+	region := tokenizeRegion(updated[:end.position+delta], start.position)
+	if end.position < len(document) && !closedBeforeEnd(region, end.position+delta) {
+		region = tokenizeRegion(updated, start.position)
+		end, region = commonCut(cuts[last+1:], topLevelCuts(updated, region)[1:], delta, region)
+	}
+	if hasDefinitions(list[start.index:end.index]) || hasDefinitions(region) {
+		return updated, BuildDjotTokens(updated)
+	}
+	tokens := make(tokenizer.TokenList[DjotToken], 0, start.index+len(region)+len(list)-end.index)
+	tokens = append(tokens, list[:start.index]...)
+	tokens = append(tokens, region[1:len(region)-1]...)
+	for _, token := range list[end.index : len(list)-1] {
+		token.Start, token.End = token.Start+delta, token.End+delta
+		tokens = append(tokens, token)
+	}
+	tokens = append(tokens, tokenizer.Token[DjotToken]{Type: DocumentBlock ^ tokenizer.Open, Start: len(updated), End: len(updated)})
+	tokens[0].JumpToPair, tokens[len(tokens)-1].JumpToPair = len(tokens)-1, -(len(tokens) - 1)
+	return updated, tokens
+}
+
+// commonCut finds the first old cut which is also a cut of the tail tokenized from the updated document and returns
+// it with the tail truncated at it; document end is common to both so the search always succeeds
+func commonCut(old, updated []cut, delta int, tail tokenizer.TokenList[DjotToken]) (cut, tokenizer.TokenList[DjotToken]) {
+	i, j := 0, 0
+	for old[i].position+delta != updated[j].position {
+		if old[i].position+delta < updated[j].position {
+			i++
+		} else {
+			j++
+		}
+	}
+	region := append(tail[:updated[j].index:updated[j].index], tail[len(tail)-1])
+	return old[i], region
+}
+
+// closedBeforeEnd checks that the last top level block of the region was closed strictly before its end
+func closedBeforeEnd(region tokenizer.TokenList[DjotToken], end int) bool {
+	lastEnd := -1
+	for i := 1; i < len(region)-1; i += max(region[i].JumpToPair, 0) + 1 {
+		lastEnd = region[i+max(region[i].JumpToPair, 0)].End
+	}
+	return lastEnd < end
+}
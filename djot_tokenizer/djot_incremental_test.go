@@ -0,0 +1,133 @@
+package djot_tokenizer
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"md0.org/djot/internal/testx"
+	"md0.org/djot/tokenizer"
+)
+
+var incrementalSnippets = []string{
+	"# Heading *with* markup\n",
+	"paragraph with _emphasis_ and `code`\nsecond line\n",
+	"\n",
+	"\n\n",
+	"- item one\n- item two\n\n  nested paragraph\n",
+	"1. first\n2. second\n",
+	"> quote\n> continued\n",
+	"```go\nx := 1\n\ny := 2\n```\n",
+	"::: warning\ninside div\n:::\n",
+	"| a | b |\n|---|--:|\n| c | d |\n",
+	"^ caption\n",
+	"{#id .class}\n",
+	"***\n",
+	"[link][ref] and ![image](src.png)\n",
+	"[ref]: https://example.com\n",
+	"[^note]: footnote text\n\n  more footnote\n",
+	"text with [^note] reference\n",
+	"$$`x^2`\n",
+	"  indented text\n",
+	": term\n\n  definition\n",
+}
+
+func randomDocument(r *rand.Rand, blocks int) string {
+	var builder strings.Builder
+	for i := 0; i < blocks; i++ {
+		builder.WriteString(incrementalSnippets[r.Intn(len(incrementalSnippets))])
+	}
+	return builder.String()
+}
+
+func randomEdit(r *rand.Rand, document []byte) Edit {
+	start := r.Intn(len(document) + 1)
+	end := min(len(document), start+r.Intn(12))
+	var text string
+	switch r.Intn(4) {
+	case 0:
+		text = ""
+	case 1:
+		text = incrementalSnippets[r.Intn(len(incrementalSnippets))]
+	case 2:
+		symbols := "\n *_`[]{}#>|-:^"
+		text = string(symbols[r.Intn(len(symbols))])
+	default:
+		text = "word"
+	}
+	return Edit{Start: start, End: end, Text: []byte(text)}
+}
+
+func cutPositions(document []byte, list tokenizer.TokenList[DjotToken]) []int {
+	positions := make([]int, 0)
+	for _, c := range topLevelCuts(document, list) {
+		positions = append(positions, c.position)
+	}
+	return positions
+}
+
+// countTokenized makes tokenizeRegion count bytes it tokenizes until the test ends
+func countTokenized(t *testing.T) *int {
+	tokenized := 0
+	tokenizeRegion = func(document []byte, start int) tokenizer.TokenList[DjotToken] {
+		tokenized += len(document) - start
+		return buildDjotTokens(document, start)
+	}
+	t.Cleanup(func() { tokenizeRegion = buildDjotTokens })
+	return &tokenized
+}
+
+func TestUpdateDjotTokens(t *testing.T) {
+	t.Run("reuses unaffected blocks", func(t *testing.T) {
+		tokenized := countTokenized(t)
+		document := []byte("# Title\n\nfirst paragraph\n\nsecond paragraph\n")
+		list := BuildDjotTokens(document)
+		updated, tokens := UpdateDjotTokens(document, list, Edit{Start: 9, End: 14, Text: []byte("changed")})
+		testx.AssertEqual(t, "", "# Title\n\nchanged paragraph\n\nsecond paragraph\n", string(updated))
+		testx.AssertEqual(t, "", BuildDjotTokens(updated), tokens)
+		testx.AssertEqual(t, "", []int{0, 9, 28, 45}, cutPositions(updated, tokens))
+		testx.AssertEqual(t, "", len("changed paragraph\n\n"), *tokenized)
+	})
+	t.Run("no cut inside of the table", func(t *testing.T) {
+		document := []byte("| a |\n\n^ caption\n\npara\n")
+		testx.AssertEqual(t, "", []int{0, 18, 23}, cutPositions(document, BuildDjotTokens(document)))
+	})
+	t.Run("unclosed code block in large document", func(t *testing.T) {
+		tokenized := countTokenized(t)
+		document := []byte("# Title\n\n" + strings.Repeat("paragraph with `code`\n\n", 2500))
+		updated, tokens := UpdateDjotTokens(document, BuildDjotTokens(document), Edit{Start: 9, End: 9, Text: []byte("```\n")})
+		testx.AssertEqual(t, "", BuildDjotTokens(updated), tokens)
+		// the block up to the next cut and then the rest of the document are tokenized once
+		testx.AssertEqual(t, "", len("```\nparagraph with `code`\n\n")+len(updated)-9, *tokenized)
+	})
+	t.Run("random edits", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		for iteration := 0; iteration < 300; iteration++ {
+			document := []byte(randomDocument(r, 1+r.Intn(12)))
+			list := BuildDjotTokens(document)
+			for step := 0; step < 10; step++ {
+				edit := randomEdit(r, document)
+				before := string(document)
+				document, list = UpdateDjotTokens(document, list, edit)
+				if !testx.AssertEqual(t, "", BuildDjotTokens(document), list) {
+					t.Fatalf("document %q, edit %+v", before, edit)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkUpdateDjotTokens(b *testing.B) {
+	document := []byte("# Title\n\n" + strings.Repeat("paragraph with `code`\n\n", 2500))
+	list := BuildDjotTokens(document)
+	b.Run("paragraph", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			UpdateDjotTokens(document, list, Edit{Start: 9, End: 18, Text: []byte("changed")})
+		}
+	})
+	b.Run("unclosed code block", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			UpdateDjotTokens(document, list, Edit{Start: 9, End: 9, Text: []byte("```\n")})
+		}
+	})
+}
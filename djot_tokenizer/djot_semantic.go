@@ -0,0 +1,176 @@
+package djot_tokenizer
+
+import (
+	"bytes"
+	"fmt"
+
+	"md0.org/djot/tokenizer"
+)
+
+type SemanticClass int
+
+const (
+	TextClass        SemanticClass = iota
+	DelimiterClass                 // markup symbols: heading and list markers, emphasis delimiters, brackets, fences
+	AttributeClass                 // attributes, code block language, div class and raw format
+	HeadingClass                   // text of the heading
+	CodeClass                      // content of verbatim and code blocks
+	MathClass                      // content of inline and display math
+	DestinationClass               // link destination, autolink and reference definition url
+	LabelClass                     // reference and footnote label
+	EscapeClass                    // escaped symbol
+	SymbolClass                    // name of the :symbol:
+)
+
+func (c SemanticClass) String() string {
+	switch c {
+	case TextClass:
+		return "text"
+	case DelimiterClass:
+		return "delimiter"
+	case AttributeClass:
+		return "attribute"
+	case HeadingClass:
+		return "heading"
+	case CodeClass:
+		return "code"
+	case MathClass:
+		return "math"
+	case DestinationClass:
+		return "destination"
+	case LabelClass:
+		return "label"
+	case EscapeClass:
+		return "escape"
+	case SymbolClass:
+		return "symbol"
+	}
+	panic(fmt.Errorf("unexpected semantic class: %d", c))
+}
+
+type SemanticToken struct {
+	Class SemanticClass
+	Range tokenizer.Range
+}
+
+type semanticBuilder struct {
+	document []byte
+	tokens   []SemanticToken
+	position int
+}
+
+// push classifies bytes from the current position until the end; spaces and line breaks around the heading,
+// attribute, destination and label are left as text
+func (b *semanticBuilder) push(end int, class SemanticClass) {
+	switch class {
+	case HeadingClass, AttributeClass, DestinationClass, LabelClass:
+		if end <= b.position {
+			return
+		}
+		content := bytes.TrimSpace(b.document[b.position:end])
+		if len(content) == 0 {
+			b.append(end, TextClass)
+			return
+		}
+		start := b.position + bytes.Index(b.document[b.position:end], content)
+		b.append(start, TextClass)
+		b.append(start+len(content), class)
+		b.append(end, TextClass)
+	default:
+		b.append(end, class)
+	}
+}
+
+// append classifies bytes from the current position until the end; adjacent ranges of the same class are merged
+func (b *semanticBuilder) append(end int, class SemanticClass) {
+	if end <= b.position {
+		return
+	}
+	if last := len(b.tokens) - 1; last >= 0 && b.tokens[last].Class == class {
+		b.tokens[last].Range.End = end
+	} else {
+		b.tokens = append(b.tokens, SemanticToken{Class: class, Range: tokenizer.Range{Start: b.position, End: end}})
+	}
+	b.position = end
+}
+
+// contextClass returns class of the text inside the innermost open token which affects text classification
+func contextClass(document []byte, stack []tokenizer.Token[DjotToken]) SemanticClass {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch stack[i].Type {
+		case VerbatimInline:
+			if document[stack[i].Start] == '$' {
+				return MathClass
+			}
+			return CodeClass
+		case CodeBlock:
+			return CodeClass
+		case RawFormatInline:
+			return AttributeClass
+		case LinkUrlInline, AutolinkInline, ReferenceDefBlock:
+			return DestinationClass
+		case LinkReferenceInline, FootnoteReferenceInline:
+			return LabelClass
+		case SymbolsInline:
+			return SymbolClass
+		case HeadingBlock:
+			return HeadingClass
+		}
+	}
+	return TextClass
+}
+
+// SemanticTokens converts list built by BuildDjotTokens into flat sequence of non-overlapping classified ranges
+// which covers the whole document; it can be used to color djot source in editors
+func SemanticTokens(document []byte, list tokenizer.TokenList[DjotToken]) []SemanticToken {
+	b := semanticBuilder{document: document, tokens: make([]SemanticToken, 0)}
+	stack := make([]tokenizer.Token[DjotToken], 0)
+	for _, token := range list {
+		context := contextClass(document, stack)
+		if gap := document[min(b.position, token.Start):token.Start]; context == TextClass && len(bytes.TrimSpace(gap)) > 0 {
+			// only block prefixes like '>' in the empty line of the quote are left untokenized
+			b.push(token.Start, DelimiterClass)
+		} else {
+			b.push(token.Start, context)
+		}
+		switch {
+		case token.JumpToPair < 0:
+			stack = stack[:len(stack)-1]
+			b.push(token.End, DelimiterClass)
+		case token.JumpToPair > 0:
+			stack = append(stack, token)
+			switch token.Type {
+			case ReferenceDefBlock, FootnoteDefBlock:
+				// [label]: and [^label]: are split into brackets and label
+				open := len("[")
+				if token.Type == FootnoteDefBlock {
+					open = len("[^")
+				}
+				b.push(token.Start+open, DelimiterClass)
+				b.push(token.End-len("]:"), LabelClass)
+				b.push(token.End, DelimiterClass)
+			case CodeBlock, DivBlock:
+				b.push(token.End, DelimiterClass)
+				lineEnd := bytes.IndexByte(document[token.End:], '\n')
+				if lineEnd == -1 {
+					lineEnd = len(document) - token.End
+				}
+				b.push(token.End+lineEnd, AttributeClass)
+			default:
+				b.push(token.End, DelimiterClass)
+			}
+		case token.Type == Attribute:
+			b.push(token.End, AttributeClass)
+		case token.Type == EscapedSymbolInline:
+			b.push(token.End, EscapeClass)
+		case token.Type == ThematicBreakToken:
+			b.push(token.End, DelimiterClass)
+		case token.Type == Ignore && len(bytes.TrimSpace(document[token.Start:token.End])) > 0:
+			b.push(token.End, DelimiterClass)
+		default:
+			b.push(token.End, context)
+		}
+	}
+	b.push(len(document), TextClass)
+	return b.tokens
+}
@@ -0,0 +1,40 @@
+package djot_tokenizer
+
+import (
+	"math/rand"
+	"testing"
+
+	"md0.org/djot/internal/testx"
+)
+
+func TestSemanticTokens(t *testing.T) {
+	t.Run("classes", func(t *testing.T) {
+		document := []byte("> [^a] $`x` :+1: <http://b>\n>\n\n``` go\ncode\n```\n\n[^a]: note {.c}\n")
+		classified := make([]string, 0)
+		for _, token := range SemanticTokens(document, BuildDjotTokens(document)) {
+			classified = append(classified, token.Class.String()+":"+string(document[token.Range.Start:token.Range.End]))
+		}
+		testx.AssertEqual(t, "", []string{
+			"delimiter:> [^", "label:a", "delimiter:]", "text: ", "delimiter:$`", "math:x", "delimiter:`", "text: ",
+			"delimiter::", "symbol:+1", "delimiter::", "text: ", "delimiter:<", "destination:http://b", "delimiter:>",
+			"text:\n", "delimiter:>\n", "text:\n", "delimiter:```", "text: ", "attribute:go", "code:\ncode\n", "delimiter:```\n",
+			"text:\n", "delimiter:[^", "label:a", "delimiter:]:", "text: note ", "attribute:{.c}", "text:\n",
+		}, classified)
+	})
+	t.Run("covers document", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		for iteration := 0; iteration < 300; iteration++ {
+			document := []byte(randomDocument(r, 1+r.Intn(12)))
+			tokens, position := SemanticTokens(document, BuildDjotTokens(document)), 0
+			for i, token := range tokens {
+				testx.AssertEqual(t, "", position, token.Range.Start)
+				testx.AssertTrue(t, "", token.Range.End > token.Range.Start)
+				if i > 0 {
+					testx.AssertTrue(t, "", token.Class != tokens[i-1].Class)
+				}
+				position = token.Range.End
+			}
+			testx.AssertEqual(t, "", len(document), position)
+		}
+	})
+}
@@ -1,6 +1,12 @@
 package djot_tokenizer
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"md0.org/djot/tokenizer"
+)
 
 type DjotToken int
 
@@ -130,3 +136,31 @@ func (t DjotToken) String() string {
 	}
 	panic(fmt.Errorf("unexpected djot token type: %d", t))
 }
+
+// DumpDjotTokens writes tokens one per line: index, type indented by the nesting of the pairs, source range, quoted
+// source text and attributes; paired tokens show index of their pair after -> (opening) or <- (closing)
+func DumpDjotTokens(document []byte, list tokenizer.TokenList[DjotToken]) string {
+	var builder strings.Builder
+	width, depth := len(strconv.Itoa(len(list)-1)), 0
+	for i, token := range list {
+		if token.JumpToPair < 0 {
+			depth--
+		}
+		fmt.Fprintf(&builder, "%*d %v%v %d-%d", width, i, strings.Repeat("  ", max(depth, 0)), token.Type, token.Start, token.End)
+		if token.End > token.Start {
+			builder.WriteString(" " + strconv.Quote(string(document[token.Start:token.End])))
+		}
+		for _, entry := range token.Attributes.Entries() {
+			builder.WriteString(" " + entry.Key + "=" + strconv.Quote(entry.Value))
+		}
+		switch {
+		case token.JumpToPair > 0:
+			fmt.Fprintf(&builder, " -> %d", i+token.JumpToPair)
+			depth++
+		case token.JumpToPair < 0:
+			fmt.Fprintf(&builder, " <- %d", i+token.JumpToPair)
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
@@ -153,6 +153,11 @@ func BuildInlineDjotTokens(
 }
 
 func BuildDjotTokens(document []byte) tokenizer.TokenList[DjotToken] {
+	return buildDjotTokens(document, 0)
+}
+
+// buildDjotTokens tokenizes document[start:] as top level content; start must be a start of the line
+func buildDjotTokens(document []byte, start int) tokenizer.TokenList[DjotToken] {
 	var (
 		lineTokenizer = tokenizer.LineTokenizer{Document: document}
 
@@ -161,9 +166,10 @@ func BuildDjotTokens(document []byte) tokenizer.TokenList[DjotToken] {
 		blockLineOffset  = []int{0}
 		blockTokenOffset = []int{0}
 
-		blockTokens = []tokenizer.Token[DjotToken]{{Type: DocumentBlock, Start: 0, End: 0}}
-		finalTokens = []tokenizer.Token[DjotToken]{{Type: DocumentBlock, Start: 0, End: 0}}
+		blockTokens = []tokenizer.Token[DjotToken]{{Type: DocumentBlock, Start: start, End: start}}
+		finalTokens = []tokenizer.Token[DjotToken]{{Type: DocumentBlock, Start: start, End: start}}
 	)
+	lineTokenizer.Seek(start)
 
 	popMetadata := func() {
 		blockLineOffset = blockLineOffset[:len(blockLineOffset)-1]
@@ -236,7 +242,7 @@ func BuildDjotTokens(document []byte) tokenizer.TokenList[DjotToken] {
 			}
 		}
 		// Skip optional padding for Heading & Quotes (#, > padding) and remember last matched block token
-		resetBlockAt, potentialReset := 0, false
+		resetBlockAt, potentialReset, resetFootnote := 0, false, false
 		for i := 0; i < len(blockTokens); i++ {
 			blockToken := blockTokens[i]
 			if blockToken.Type == ListItemBlock || blockToken.Type == FootnoteDefBlock {
@@ -244,7 +250,7 @@ func BuildDjotTokens(document []byte) tokenizer.TokenList[DjotToken] {
 				tokenizer.Assertf(ok, "MaskRepeat must match because minCount is zero")
 
 				if !reader.IsEmptyOrWhiteSpace(next) && next-lineStart <= blockLineOffset[i] {
-					potentialReset = true
+					potentialReset, resetFootnote = true, blockToken.Type == FootnoteDefBlock
 					break
 				}
 				resetBlockAt = i
@@ -274,6 +280,12 @@ func BuildDjotTokens(document []byte) tokenizer.TokenList[DjotToken] {
 			continue
 		}
 
+		// Not indented line finishes the footnote unless it lazily continues the paragraph
+		if resetFootnote && lastBlockType != ParagraphBlock && lastBlockType != HeadingBlock && lastBlockType != PipeTableCaptionBlock && lastBlockType != CodeBlock {
+			closeBlockLevelsUntil(state, state, resetBlockAt)
+			lastBlock, lastBlockType, lastDivAt = blockTokens[len(blockTokens)-1], blockTokens[len(blockTokens)-1].Type, -1
+		}
+
 		// Force resets for ReferenceDefBlock if any
 		if lastBlockType == ReferenceDefBlock {
 			closeBlockLevelsUntil(state, state, resetBlockAt)
@@ -1,6 +1,7 @@
 package djot_tokenizer
 
 import (
+	"strings"
 	"testing"
 
 	"md0.org/djot/internal/testx"
@@ -140,3 +141,75 @@ func TestUnmatchedEmphasis(t *testing.T) {
 		{Type: DocumentBlock ^ tokenizer.Open, Start: 37, End: 37, JumpToPair: -8},
 	}, tokens)
 }
+
+// blockLines keeps only block tokens of the dump, so nesting of the blocks is easy to see
+func blockLines(document string) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(DumpDjotTokens([]byte(document), BuildDjotTokens([]byte(document))), "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && strings.Contains(fields[1], "Block") {
+			lines = append(lines, strings.Join(fields[:2], " "))
+		}
+	}
+	return lines
+}
+
+func TestFootnoteDefinitionEnd(t *testing.T) {
+	for _, test := range []struct {
+		name, document string
+		blocks         []string
+	}{
+		{
+			name:     "paragraph after footnote",
+			document: "[^a]: note\n\nafter\n",
+			blocks: []string{
+				"0 DocumentBlock", "1 FootnoteDefBlock", "2 ParagraphBlock", "5 ParagraphBlockClose", "6 FootnoteDefBlockClose",
+				"7 ParagraphBlock", "10 ParagraphBlockClose", "11 DocumentBlockClose",
+			},
+		},
+		{
+			name:     "lazy continuation",
+			document: "[^a]: note\nlazy\n",
+			blocks: []string{
+				"0 DocumentBlock", "1 FootnoteDefBlock", "2 ParagraphBlock", "7 ParagraphBlockClose", "8 FootnoteDefBlockClose",
+				"9 DocumentBlockClose",
+			},
+		},
+		{
+			name:     "indented second paragraph",
+			document: "[^a]: note\n\n  second\n\nafter\n",
+			blocks: []string{
+				"0 DocumentBlock", "1 FootnoteDefBlock", "2 ParagraphBlock", "5 ParagraphBlockClose", "6 ParagraphBlock",
+				"9 ParagraphBlockClose", "10 FootnoteDefBlockClose", "11 ParagraphBlock", "14 ParagraphBlockClose",
+				"15 DocumentBlockClose",
+			},
+		},
+		{
+			name:     "indented code block",
+			document: "[^a]: note\n\n  ```\n  code\n  ```\n",
+			blocks: []string{
+				"0 DocumentBlock", "1 FootnoteDefBlock", "2 ParagraphBlock", "5 ParagraphBlockClose", "6 CodeBlock",
+				"8 CodeBlockClose", "9 FootnoteDefBlockClose", "10 DocumentBlockClose",
+			},
+		},
+		{
+			name:     "code block after footnote",
+			document: "[^a]: note\n\n```\ncode\n```\n\nafter\n",
+			blocks: []string{
+				"0 DocumentBlock", "1 FootnoteDefBlock", "2 ParagraphBlock", "5 ParagraphBlockClose", "6 FootnoteDefBlockClose",
+				"7 CodeBlock", "9 CodeBlockClose", "10 ParagraphBlock", "13 ParagraphBlockClose", "14 DocumentBlockClose",
+			},
+		},
+		{
+			name:     "table after footnote",
+			document: "[^a]: note\n\n| a |\n",
+			blocks: []string{
+				"0 DocumentBlock", "1 FootnoteDefBlock", "2 ParagraphBlock", "5 ParagraphBlockClose", "6 FootnoteDefBlockClose",
+				"7 PipeTableBlock", "12 PipeTableBlockClose", "13 DocumentBlockClose",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			testx.AssertEqual(t, "", test.blocks, blockLines(test.document))
+		})
+	}
+}
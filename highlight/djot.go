@@ -0,0 +1,52 @@
+package highlight
+
+import (
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/html_writer"
+	"md0.org/djot/tokenizer"
+)
+
+var djotClasses = map[djot_tokenizer.SemanticClass]string{
+	djot_tokenizer.TextClass:        "",
+	djot_tokenizer.DelimiterClass:   MarkupClass,
+	djot_tokenizer.AttributeClass:   AttributeClass,
+	djot_tokenizer.HeadingClass:     HeadingClass,
+	djot_tokenizer.CodeClass:        CodeClass,
+	djot_tokenizer.MathClass:        MathClass,
+	djot_tokenizer.DestinationClass: LinkClass,
+	djot_tokenizer.LabelClass:       LabelClass,
+	djot_tokenizer.EscapeClass:      EscapeClass,
+	djot_tokenizer.SymbolClass:      SymbolClass,
+}
+
+// DjotLexer classifies djot source with the djot tokenizer so highlighting matches the way document is parsed
+var DjotLexer = LexerFunc(func(code []byte) []djot_parser.HighlightToken {
+	semantic := djot_tokenizer.SemanticTokens(code, djot_tokenizer.BuildDjotTokens(code))
+	tokens := make([]djot_parser.HighlightToken, 0, len(semantic))
+	for _, token := range semantic {
+		tokens = append(tokens, djot_parser.HighlightToken{
+			Class: djotClasses[token.Class],
+			Text:  code[token.Range.Start:token.Range.End],
+		})
+	}
+	return tokens
+})
+
+// RenderDjotSource writes highlighted djot source as a code block; attributes are applied to the code block
+// so {hl="2" linenos=true} options work as well
+func RenderDjotSource(writer *html_writer.HtmlWriter, document []byte, attributes ...tokenizer.AttributeEntry) *html_writer.HtmlWriter {
+	node := djot_parser.TreeNode[djot_parser.DjotNode]{
+		Type:     djot_parser.CodeNode,
+		Children: []djot_parser.TreeNode[djot_parser.DjotNode]{{Type: djot_parser.TextNode, Text: document}},
+	}
+	node.Attributes.Set(djot_tokenizer.CodeLangKey, "djot")
+	node.Attributes.Set(djot_tokenizer.DjotAttributeClassKey, "language-djot")
+	for _, entry := range attributes {
+		node.Attributes.Set(entry.Key, entry.Value)
+	}
+	context := djot_parser.NewConversionContext("html")
+	context.Options.Highlighter = Highlighter{"djot": DjotLexer}
+	context.ConvertDjotToHtml(writer, node)
+	return writer
+}
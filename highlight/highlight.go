@@ -0,0 +1,125 @@
+package highlight
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"md0.org/djot/djot_parser"
+)
+
+const (
+	KeywordClass     = "hl-keyword"
+	TypeClass        = "hl-type"
+	BuiltinClass     = "hl-builtin"
+	FunctionClass    = "hl-function"
+	LiteralClass     = "hl-literal"
+	StringClass      = "hl-string"
+	NumberClass      = "hl-number"
+	CommentClass     = "hl-comment"
+	OperatorClass    = "hl-operator"
+	PunctuationClass = "hl-punctuation"
+	VariableClass    = "hl-variable"
+	KeyClass         = "hl-key"
+	MarkupClass      = "hl-markup"
+	HeadingClass     = "hl-heading"
+	CodeClass        = "hl-code"
+	LinkClass        = "hl-link"
+	AttributeClass   = "hl-attribute"
+	MathClass        = "hl-math"
+	LabelClass       = "hl-label"
+	EscapeClass      = "hl-escape"
+	SymbolClass      = "hl-symbol"
+)
+
+type (
+	Lexer interface {
+		Lex(code []byte) []djot_parser.HighlightToken
+	}
+	LexerFunc func(code []byte) []djot_parser.HighlightToken
+
+	// Highlighter maps lowercase language names to lexers and implements djot_parser.Highlighter
+	Highlighter map[string]Lexer
+)
+
+func (f LexerFunc) Lex(code []byte) []djot_parser.HighlightToken { return f(code) }
+
+func (h Highlighter) Highlight(language string, code []byte) ([]djot_parser.HighlightToken, bool) {
+	lexer, ok := h[strings.ToLower(language)]
+	if !ok {
+		return nil, false
+	}
+	return lexer.Lex(code), true
+}
+
+var Default = Highlighter{
+	"go":     GoLexer,
+	"golang": GoLexer,
+	"sh":     ShellLexer,
+	"shell":  ShellLexer,
+	"bash":   ShellLexer,
+	"json":   JsonLexer,
+	"yaml":   YamlLexer,
+	"yml":    YamlLexer,
+	"djot":   DjotLexer,
+}
+
+type Rule struct {
+	Class     string
+	Pattern   *regexp.Regexp
+	LineStart bool // rule can match only at the start of the line
+}
+
+// NewRule compiles rule anchored at the current position; if pattern has capture groups, the token spans only
+// the first group and the rest of the match works as a lookahead
+func NewRule(class, pattern string) Rule {
+	return Rule{Class: class, Pattern: regexp.MustCompile(`\A(?:` + pattern + `)`)}
+}
+
+func NewLineStartRule(class, pattern string) Rule {
+	rule := NewRule(class, pattern)
+	rule.LineStart = true
+	return rule
+}
+
+// RuleLexer tries rules in order at every position, first matched rule wins and symbols without rules are plain text
+type RuleLexer []Rule
+
+func (l RuleLexer) Lex(code []byte) []djot_parser.HighlightToken {
+	tokens := make([]djot_parser.HighlightToken, 0)
+	position, tokenStart := 0, 0
+	for position < len(code) {
+		lineStart := position == 0 || code[position-1] == '\n'
+		end, class := position, ""
+		for _, rule := range l {
+			if rule.LineStart && !lineStart {
+				continue
+			}
+			match := rule.Pattern.FindSubmatchIndex(code[position:])
+			if match == nil {
+				continue
+			}
+			length := match[1]
+			if len(match) > 2 && match[3] >= 0 {
+				length = match[3]
+			}
+			if length == 0 {
+				continue
+			}
+			end, class = position+length, rule.Class
+			break
+		}
+		if end == position {
+			_, size := utf8.DecodeRune(code[position:])
+			end = position + size
+		}
+		if last := len(tokens) - 1; last >= 0 && tokens[last].Class == class {
+			tokens[last].Text = code[tokenStart:end]
+		} else {
+			tokenStart = position
+			tokens = append(tokens, djot_parser.HighlightToken{Class: class, Text: code[position:end]})
+		}
+		position = end
+	}
+	return tokens
+}
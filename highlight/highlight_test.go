@@ -0,0 +1,67 @@
+package highlight
+
+import (
+	"testing"
+
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/html_writer"
+	"md0.org/djot/internal/testx"
+	"md0.org/djot/tokenizer"
+)
+
+func classes(tokens []djot_parser.HighlightToken) []string {
+	result := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, token.Class+":"+string(token.Text))
+	}
+	return result
+}
+
+func TestLexers(t *testing.T) {
+	for _, tt := range []struct {
+		language string
+		code     string
+		tokens   []string
+	}{
+		{language: "go", code: `x := len(s) // n`, tokens: []string{
+			":x ", "hl-operator::=", ": ", "hl-builtin:len", "hl-punctuation:(", ":s", "hl-punctuation:)", ": ", "hl-comment:// n",
+		}},
+		{language: "go", code: "format(nil)", tokens: []string{"hl-function:format", "hl-punctuation:(", "hl-literal:nil", "hl-punctuation:)"}},
+		{language: "bash", code: `echo "$x" done.txt`, tokens: []string{"hl-builtin:echo", ": ", `hl-string:"$x"`, ": done.txt"}},
+		{language: "json", code: `{"a": 1}`, tokens: []string{"hl-punctuation:{", `hl-key:"a"`, "hl-punctuation::", ": ", "hl-number:1", "hl-punctuation:}"}},
+		{language: "YAML", code: "a: http://x # c", tokens: []string{"hl-key:a", "hl-punctuation::", ": http://x ", "hl-comment:# c"}},
+	} {
+		t.Run(tt.language+":"+tt.code, func(t *testing.T) {
+			tokens, ok := Default.Highlight(tt.language, []byte(tt.code))
+			testx.AssertTrue(t, "", ok)
+			testx.AssertEqual(t, "", tt.tokens, classes(tokens))
+		})
+	}
+	t.Run("unknown language", func(t *testing.T) {
+		_, ok := Default.Highlight("cobol", []byte("DISPLAY 'X'"))
+		testx.AssertFalse(t, "", ok)
+	})
+}
+
+func TestHighlightedCodeBlock(t *testing.T) {
+	ast := djot_parser.BuildDjotAst([]byte("{hl=\"2\" linenos=true}\n``` go\nx := 1\nreturn x\n```"))
+	context := djot_parser.NewConversionContext("html")
+	context.Options.Highlighter = Default
+	testx.AssertEqual(t, "", `<pre><code class="language-go">`+
+		`<span class="line"><span class="line-number">1</span>x <span class="hl-operator">:=</span> <span class="hl-number">1</span></span>`+"\n"+
+		`<span class="line hl-line"><span class="line-number">2</span><span class="hl-keyword">return</span> x</span>`+"\n"+
+		"</code></pre>\n", context.ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
+}
+
+func TestDjotSource(t *testing.T) {
+	tokens, ok := Default.Highlight("djot", []byte("# *Hi*\n\nSee [x][ref] and `code`\\*\n\n[ref]: /url\n"))
+	testx.AssertTrue(t, "", ok)
+	testx.AssertEqual(t, "", []string{
+		"hl-markup:# *", "hl-heading:Hi", "hl-markup:*", ":\n\nSee ", "hl-markup:[", ":x",
+		"hl-markup:][", "hl-label:ref", "hl-markup:]", ": and ", "hl-markup:`", "hl-code:code", "hl-markup:`",
+		"hl-escape:\\*", ":\n\n", "hl-markup:[", "hl-label:ref", "hl-markup:]:", ": ", "hl-link:/url", ":\n",
+	}, classes(tokens))
+	testx.AssertEqual(t, "", `<pre><code class="language-djot">`+
+		`<span class="line"><span class="line-number">1</span><span class="hl-markup">_</span>x<span class="hl-markup">_</span></span>`+"\n"+
+		"</code></pre>\n", RenderDjotSource(&html_writer.HtmlWriter{}, []byte("_x_\n"), tokenizer.AttributeEntry{Key: djot_parser.LineNumbersKey, Value: "true"}).String())
+}
@@ -0,0 +1,55 @@
+package highlight
+
+const (
+	identifier = `[\p{L}_][\p{L}\p{N}_]*`
+	// shellWordEnd is used as a lookahead for shell keywords which must be separate words
+	shellWordEnd = `(?:[\s;&|()]|\z)`
+	// yamlScalarEnd is used as a lookahead for plain YAML scalars which must fill the whole value
+	yamlScalarEnd = `(?:[ \t]*(?:[\n#,\]}]|\z))`
+)
+
+var GoLexer = RuleLexer{
+	NewRule(CommentClass, `//[^\n]*|/\*[\s\S]*?(?:\*/|\z)`),
+	NewRule(StringClass, "`[^`]*`?"+`|"(?:\\.|[^"\\\n])*"?|'(?:\\.|[^'\\\n])*'?`),
+	NewRule(KeywordClass, `(?:break|case|chan|const|continue|default|defer|else|fallthrough|for|func|go|goto|if|import|interface|map|package|range|return|select|struct|switch|type|var)\b`),
+	NewRule(TypeClass, `(?:any|bool|byte|comparable|complex64|complex128|error|float32|float64|int|int8|int16|int32|int64|rune|string|uint|uint8|uint16|uint32|uint64|uintptr)\b`),
+	NewRule(LiteralClass, `(?:true|false|nil|iota)\b`),
+	NewRule(BuiltinClass, `(append|cap|clear|close|complex|copy|delete|imag|len|make|max|min|new|panic|print|println|real|recover)\(`),
+	NewRule(FunctionClass, `(`+identifier+`)\(`),
+	NewRule("", identifier),
+	NewRule(NumberClass, `(?:0[xX][0-9a-fA-F_]+|0[bB][01_]+|0[oO][0-7_]+|(?:\d[\d_]*(?:\.\d*)?|\.\d+)(?:[eE][+-]?\d+)?)i?`),
+	NewRule(OperatorClass, `[-+*/%&|^<>=!:.~]+`),
+	NewRule(PunctuationClass, `[()\[\]{},;]`),
+}
+
+var ShellLexer = RuleLexer{
+	NewRule(CommentClass, `#[^\n]*`),
+	NewRule(StringClass, `'[^']*'?|"(?:\\.|[^"\\])*"?`),
+	NewRule(VariableClass, `\$(?:\{[^}\n]*\}?|[A-Za-z_]\w*|[0-9@#?$!*-])`),
+	NewRule(KeywordClass, `(if|then|else|elif|fi|for|while|until|do|done|case|esac|function|in|select|return|export|local|readonly)`+shellWordEnd),
+	NewRule(BuiltinClass, `(alias|cd|echo|eval|exec|exit|printf|read|set|shift|source|test|trap|unset)`+shellWordEnd),
+	NewRule(OperatorClass, `&&|\|\||[|&;<>]+`),
+	NewRule(PunctuationClass, `[()]`),
+	NewRule("", `[^\s$'"|&;<>()#][^\s$'"|&;<>()]*`),
+}
+
+var JsonLexer = RuleLexer{
+	NewRule(KeyClass, `("(?:\\.|[^"\\])*")\s*:`),
+	NewRule(StringClass, `"(?:\\.|[^"\\])*"?`),
+	NewRule(NumberClass, `-?(?:0|[1-9]\d*)(?:\.\d+)?(?:[eE][+-]?\d+)?`),
+	NewRule(LiteralClass, `(?:true|false|null)\b`),
+	NewRule(PunctuationClass, `[{}\[\],:]`),
+}
+
+var YamlLexer = RuleLexer{
+	NewRule(CommentClass, `#[^\n]*`),
+	NewLineStartRule(PunctuationClass, `(---|\.\.\.)(?:\s|\z)`),
+	NewRule(KeyClass, `([^\s#'"\[\]{},:&*!|>%@-](?:[^\n:#]|:\S)*?|"(?:\\.|[^"\\\n])*"|'[^'\n]*')[ \t]*:(?:\s|\z)`),
+	NewRule(StringClass, `"(?:\\.|[^"\\])*"?|'(?:''|[^'])*'?`),
+	NewRule(VariableClass, `[&*][^\s,\[\]{}]+`),
+	NewRule(TypeClass, `![^\s]*`),
+	NewRule(LiteralClass, `(true|false|null|True|False|Null|TRUE|FALSE|NULL|~)`+yamlScalarEnd),
+	NewRule(NumberClass, `([-+]?(?:0x[0-9a-fA-F]+|0o[0-7]+|\d+(?:\.\d*)?(?:[eE][+-]?\d+)?|\.inf|\.nan))`+yamlScalarEnd),
+	NewRule(PunctuationClass, `([-?:])(?:\s|\z)|[,\[\]{}|>]`),
+	NewRule("", `(?:[^\s#,\[\]{}:]|:\S)+`),
+}
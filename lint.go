@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/lint"
+)
+
+// runLint checks djot files and directories (current directory by default); exit code is 1 if any problem is found
+func runLint(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	var (
+		format  = flags.String("format", "text", "output format: text (file:line:col: rule: message) or json")
+		disable = flags.String("disable", "", "comma separated rules to skip: "+strings.Join(lint.Rules, ", "))
+		slug    = flags.String("slug", defaultSlug, "section ids of the headings checked by links: "+strings.Join(slugNames(), ", "))
+	)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		log.Printf("unsupported lint output format %v", *format)
+		return 2
+	}
+	disabled, err := lint.ParseRules(*disable)
+	if err != nil {
+		log.Printf("invalid -disable value: %v", err)
+		return 2
+	}
+	slugFunc, ok := slugFunctions[*slug]
+	if !ok {
+		log.Printf("unknown section id style %v", *slug)
+		return 2
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	problems := make([]lint.Problem, 0)
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			log.Printf("failed to lint %v: %v", path, err)
+			return 2
+		}
+		// names of fs.FS can't leave its root (like ../docs), so the root is the directory itself or the parent of
+		// the file and problems are reported relative to the given path
+		root, name, dir := abs, ".", path
+		if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+			root, name, dir = filepath.Dir(abs), filepath.Base(abs), filepath.Dir(path)
+		}
+		found, err := lint.Files(os.DirFS(root), []string{name}, lint.Config{Disabled: disabled, Options: djot_parser.ParseOptions{Slug: slugFunc}})
+		if err != nil {
+			log.Printf("failed to lint %v: %v", path, err)
+			return 2
+		}
+		for _, problem := range found {
+			problem.File = filepath.Join(dir, filepath.FromSlash(problem.File))
+			problems = append(problems, problem)
+		}
+	}
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(problems); err != nil {
+			log.Printf("failed to write lint results: %v", err)
+			return 2
+		}
+	} else {
+		for _, problem := range problems {
+			fmt.Fprintln(stdout, problem)
+		}
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,208 @@
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/tokenizer"
+)
+
+const (
+	BrokenLinkRule   = "broken-link"
+	EmptyLinkRule    = "empty-link"
+	ImageAltRule     = "image-alt"
+	HeadingJumpRule  = "heading-jump"
+	TableColumnsRule = "table-columns"
+
+	Extension = ".djot"
+)
+
+// Rules lists all rules reported by Check, including parser diagnostics
+var Rules = []string{
+	djot_parser.UndefinedReferenceRule,
+	djot_parser.UnusedReferenceRule,
+	djot_parser.DuplicateReferenceRule,
+	djot_parser.UndefinedFootnoteRule,
+	djot_parser.UnusedFootnoteRule,
+	djot_parser.DuplicateFootnoteRule,
+	djot_parser.DuplicateIdRule,
+	djot_parser.UnclosedVerbatimRule,
+	BrokenLinkRule,
+	EmptyLinkRule,
+	ImageAltRule,
+	HeadingJumpRule,
+	TableColumnsRule,
+}
+
+type Config struct {
+	Disabled map[string]bool          // rules from Rules which are not reported
+	Options  djot_parser.ParseOptions // options the documents are rendered with, e.g. Slug of the section ids
+}
+
+type Problem struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%v:%v:%v: %v: %v", p.File, p.Line, p.Column, p.Rule, p.Message)
+}
+
+// Check returns parser diagnostics together with the lint rules violations ordered by position
+func Check(document []byte, config Config) []djot_parser.Diagnostic {
+	list := djot_tokenizer.BuildDjotTokens(document)
+	context := djot_parser.BuildDjotContextWithOptions(document, list, config.Options)
+	diagnostics := append(djot_parser.BuildDjotDiagnostics(document, context, list), checkTokens(document, context, list)...)
+	result := make([]djot_parser.Diagnostic, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		if !config.Disabled[diagnostic.Rule] {
+			result = append(result, diagnostic)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Range.Start < result[j].Range.Start })
+	return result
+}
+
+// Files checks djot files and recursively all files with Extension in directories
+func Files(fsys fs.FS, paths []string, config Config) ([]Problem, error) {
+	problems := make([]Problem, 0)
+	for _, root := range paths {
+		err := fs.WalkDir(fsys, root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || (path != root && filepath.Ext(path) != Extension) {
+				return nil
+			}
+			document, err := fs.ReadFile(fsys, path)
+			if err != nil {
+				return err
+			}
+			for _, diagnostic := range Check(document, config) {
+				line, column := djot_parser.DocumentPosition(document, diagnostic.Range.Start)
+				problems = append(problems, Problem{
+					File:     path,
+					Line:     line,
+					Column:   column,
+					Severity: diagnostic.Severity.String(),
+					Rule:     diagnostic.Rule,
+					Message:  diagnostic.Message,
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return problems, nil
+}
+
+func checkTokens(document []byte, context djot_parser.DjotContext, list tokenizer.TokenList[djot_tokenizer.DjotToken]) []djot_parser.Diagnostic {
+	diagnostics := make([]djot_parser.Diagnostic, 0)
+	report := func(severity djot_parser.Severity, rule string, rng tokenizer.Range, format string, args ...any) {
+		diagnostics = append(diagnostics, djot_parser.Diagnostic{Severity: severity, Rule: rule, Message: fmt.Sprintf(format, args...), Range: rng})
+	}
+	ids := make(map[string]bool)
+	for i, token := range list {
+		if id, ok := token.Attributes.TryGet(djot_tokenizer.DjotAttributeIdKey); ok && token.Type == djot_tokenizer.Attribute {
+			ids[id] = true
+		} else if token.Type == djot_tokenizer.HeadingBlock {
+			ids[context.Options.SectionId(string(djot_parser.SelectText(document, list[i+1:i+token.JumpToPair])))] = true
+		}
+	}
+	headingLevel := 0
+	for i, token := range list {
+		if token.JumpToPair <= 0 {
+			continue
+		}
+		closeToken := list[i+token.JumpToPair]
+		switch token.Type {
+		case djot_tokenizer.HeadingBlock:
+			level := token.PrefixLength(document, '#')
+			if headingLevel > 0 && level > headingLevel+1 {
+				report(djot_parser.WarningSeverity, HeadingJumpRule, tokenizer.Range{Start: token.Start, End: closeToken.End}, "heading level jumps from %v to %v", headingLevel, level)
+			}
+			headingLevel = level
+		case djot_tokenizer.PipeTableBlock:
+			previous := i - 1
+			if previous < 0 || list[previous].Type != djot_tokenizer.PipeTableBlock^tokenizer.Open {
+				continue
+			}
+			previousColumns, columns := countColumns(list, previous+list[previous].JumpToPair), countColumns(list, i)
+			if previousColumns != columns {
+				report(djot_parser.WarningSeverity, TableColumnsRule, tokenizer.Range{Start: token.Start, End: closeToken.End}, "table row has %v cells, previous row has %v", columns, previousColumns)
+			}
+		case djot_tokenizer.SpanInline, djot_tokenizer.ImageSpanInline:
+			next := i + token.JumpToPair + 1
+			if next >= len(list) || (list[next].Type != djot_tokenizer.LinkUrlInline && list[next].Type != djot_tokenizer.LinkReferenceInline) {
+				continue
+			}
+			destination := list[next+list[next].JumpToPair]
+			rng := tokenizer.Range{Start: token.Start, End: destination.End}
+			target := bytes.TrimSpace(bytes.ReplaceAll(document[list[next].End:destination.Start], []byte("\n"), nil))
+			if list[next].Type == djot_tokenizer.LinkReferenceInline {
+				if len(target) == 0 {
+					target = djot_parser.SelectText(document, list[i+1:i+token.JumpToPair])
+				}
+				target = context.References[string(target)]
+				if len(target) == 0 {
+					continue // reported as undefined reference
+				}
+			}
+			text := bytes.TrimSpace(djot_parser.SelectText(document, list[i+1:i+token.JumpToPair]))
+			switch {
+			case token.Type == djot_tokenizer.ImageSpanInline && len(text) == 0:
+				report(djot_parser.WarningSeverity, ImageAltRule, rng, "image has no alt text")
+			case token.Type == djot_tokenizer.SpanInline && token.JumpToPair == 1:
+				report(djot_parser.WarningSeverity, EmptyLinkRule, rng, "link has no text")
+			}
+			if len(target) == 0 {
+				report(djot_parser.WarningSeverity, EmptyLinkRule, rng, "link has empty destination")
+			} else if id, ok := bytes.CutPrefix(target, []byte("#")); ok && token.Type == djot_tokenizer.SpanInline && !ids[string(id)] {
+				report(djot_parser.ErrorSeverity, BrokenLinkRule, rng, "link to undefined section '%s'", id)
+			}
+		}
+	}
+	return diagnostics
+}
+
+// countColumns counts cells among direct children of the table row
+func countColumns(list tokenizer.TokenList[djot_tokenizer.DjotToken], row int) int {
+	columns := 0
+	for s := row + 1; s < row+list[row].JumpToPair; s += max(list[s].JumpToPair, 0) + 1 {
+		if list[s].Type == djot_tokenizer.PipeTableSeparator {
+			columns++
+		}
+	}
+	return columns
+}
+
+// ParseRules parses comma separated rule names and rejects unknown ones
+func ParseRules(spec string) (map[string]bool, error) {
+	rules := make(map[string]bool)
+	known := make(map[string]bool)
+	for _, rule := range Rules {
+		known[rule] = true
+	}
+	for _, rule := range strings.Split(spec, ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		if !known[rule] {
+			return nil, fmt.Errorf("unknown rule %v", rule)
+		}
+		rules[rule] = true
+	}
+	return rules, nil
+}
@@ -0,0 +1,64 @@
+package lint
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/internal/testx"
+)
+
+func rules(document string, config Config) []string {
+	result := make([]string, 0)
+	for _, diagnostic := range Check([]byte(document), config) {
+		result = append(result, diagnostic.Rule+": "+diagnostic.Message)
+	}
+	return result
+}
+
+func TestCheck(t *testing.T) {
+	t.Run("links", func(t *testing.T) {
+		testx.AssertEqual(t, "", []string{
+			"empty-link: link has empty destination",
+			"empty-link: link has no text",
+			"broken-link: link to undefined section 'nope'",
+			"image-alt: image has no alt text",
+			"broken-link: link to undefined section 'missing'",
+		}, rules("# Intro\n\n[x]() [](#Intro) [y](#Intro) [z](#nope) ![](i.png) [r][]\n\n[r]: #missing\n", Config{}))
+	})
+	t.Run("headings and tables", func(t *testing.T) {
+		testx.AssertEqual(t, "", []string{
+			"heading-jump: heading level jumps from 1 to 3",
+			"table-columns: table row has 1 cells, previous row has 2",
+		}, rules("# A\n\n### C\n\n## D\n\n| a | b |\n|---|---|\n| c |\n", Config{}))
+	})
+	t.Run("slug", func(t *testing.T) {
+		document := "# Über Uns\n\n[a](#uber-uns) [b](#Über-Uns)\n"
+		testx.AssertEqual(t, "", []string{"broken-link: link to undefined section 'uber-uns'"}, rules(document, Config{}))
+		testx.AssertEqual(t, "", []string{"broken-link: link to undefined section 'Über-Uns'"},
+			rules(document, Config{Options: djot_parser.ParseOptions{Slug: djot_parser.ASCIISlug}}))
+	})
+	t.Run("disabled rules", func(t *testing.T) {
+		testx.AssertEqual(t, "", []string{"undefined-reference: undefined reference 'x'"},
+			rules("# A\n\n### C\n\n[a][x]\n", Config{Disabled: map[string]bool{HeadingJumpRule: true}}))
+	})
+}
+
+func TestFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"docs/a.djot":     {Data: []byte("text\n\n[see](#top)\n")},
+		"docs/b.djot":     {Data: []byte("# Top\n")},
+		"docs/notes.txt":  {Data: []byte("[see](#top)\n")},
+		"docs/sub/c.djot": {Data: []byte("[^x]\n")},
+	}
+	problems, err := Files(fsys, []string{"docs"}, Config{})
+	testx.AssertNilError(t, "", err)
+	testx.AssertEqual(t, "", []Problem{
+		{File: "docs/a.djot", Line: 3, Column: 1, Severity: "error", Rule: BrokenLinkRule, Message: "link to undefined section 'top'"},
+		{File: "docs/sub/c.djot", Line: 1, Column: 1, Severity: "error", Rule: "undefined-footnote", Message: "undefined footnote 'x'"},
+	}, problems)
+	testx.AssertEqual(t, "", "docs/a.djot:3:1: broken-link: link to undefined section 'top'", problems[0].String())
+
+	_, err = ParseRules("image-alt, unknown")
+	testx.AssertNotNil(t, "", err)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"md0.org/djot/internal/testx"
+	"md0.org/djot/lint"
+)
+
+func TestRunLint(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"ok/doc.djot":     "# Intro\n\nSee [intro](#Intro).\n",
+		"bad/doc.djot":    "# Intro\n\n[x](#nope)\n",
+		"slug/doc.djot":   "# Über Uns\n\nSee [us](#uber-uns).\n",
+		"bad/notes.txt":   "[x](#nope)\n",
+		"bad/sub/b.djot":  "![](i.png)\n",
+		"slug/readme.txt": "",
+	})
+	t.Run("exit codes", func(t *testing.T) {
+		var stdout bytes.Buffer
+		testx.AssertEqual(t, "", 0, runLint([]string{filepath.Join(dir, "ok")}, &stdout))
+		testx.AssertEqual(t, "", "", stdout.String())
+		testx.AssertEqual(t, "", 1, runLint([]string{filepath.Join(dir, "bad")}, &stdout))
+		testx.AssertEqual(t, "", filepath.Join(dir, "bad", "doc.djot")+":3:1: broken-link: link to undefined section 'nope'\n"+
+			filepath.Join(dir, "bad", "sub", "b.djot")+":1:1: image-alt: image has no alt text\n", stdout.String())
+		testx.AssertEqual(t, "", 0, runLint([]string{"-disable", "broken-link,image-alt", filepath.Join(dir, "bad")}, &stdout))
+	})
+	t.Run("invalid arguments", func(t *testing.T) {
+		testx.AssertEqual(t, "", 2, runLint([]string{"-format", "xml"}, nil))
+		testx.AssertEqual(t, "", 2, runLint([]string{"-disable", "nope"}, nil))
+		testx.AssertEqual(t, "", 2, runLint([]string{"-slug", "nope"}, nil))
+		testx.AssertEqual(t, "", 2, runLint([]string{filepath.Join(dir, "missing")}, nil))
+	})
+	t.Run("json", func(t *testing.T) {
+		var stdout bytes.Buffer
+		testx.AssertEqual(t, "", 1, runLint([]string{"-format", "json", filepath.Join(dir, "bad", "doc.djot")}, &stdout))
+		var problems []lint.Problem
+		testx.AssertNilError(t, "", json.Unmarshal(stdout.Bytes(), &problems))
+		testx.AssertEqual(t, "", []lint.Problem{{
+			File:     filepath.Join(dir, "bad", "doc.djot"),
+			Line:     3,
+			Column:   1,
+			Severity: "error",
+			Rule:     lint.BrokenLinkRule,
+			Message:  "link to undefined section 'nope'",
+		}}, problems)
+		stdout.Reset()
+		testx.AssertEqual(t, "", 0, runLint([]string{"-format", "json", filepath.Join(dir, "ok")}, &stdout))
+		testx.AssertEqual(t, "", "[]\n", stdout.String())
+	})
+	t.Run("relative path outside of working directory", func(t *testing.T) {
+		wd, err := os.Getwd()
+		testx.AssertNilError(t, "", err)
+		testx.AssertNilError(t, "", os.Chdir(filepath.Join(dir, "ok")))
+		defer func() { testx.AssertNilError(t, "", os.Chdir(wd)) }()
+		var stdout bytes.Buffer
+		testx.AssertEqual(t, "", 1, runLint([]string{filepath.Join("..", "bad")}, &stdout))
+		testx.AssertEqual(t, "", filepath.Join("..", "bad", "doc.djot")+":3:1: broken-link: link to undefined section 'nope'\n"+
+			filepath.Join("..", "bad", "sub", "b.djot")+":1:1: image-alt: image has no alt text\n", stdout.String())
+		stdout.Reset()
+		testx.AssertEqual(t, "", 1, runLint([]string{filepath.Join("..", "bad", "doc.djot")}, &stdout))
+		testx.AssertEqual(t, "", filepath.Join("..", "bad", "doc.djot")+":3:1: broken-link: link to undefined section 'nope'\n", stdout.String())
+	})
+	t.Run("slug", func(t *testing.T) {
+		var stdout bytes.Buffer
+		testx.AssertEqual(t, "", 1, runLint([]string{filepath.Join(dir, "slug")}, &stdout))
+		testx.AssertEqual(t, "", 0, runLint([]string{"-slug", "ascii", filepath.Join(dir, "slug")}, &stdout))
+	})
+}
@@ -1,75 +1,442 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"md0.org/djot/djot_lsp"
 	"md0.org/djot/djot_parser"
+	"md0.org/djot/djot_tokenizer"
+	"md0.org/djot/highlight"
 	"md0.org/djot/html_writer"
+	"md0.org/djot/mathml"
+	"md0.org/djot/tokenizer"
 )
 
+const (
+	fileClass     = "djot-file" // class of wrappers of separately rendered input files
+	fileAttribute = "data-file"
+	defaultSlug   = "default"
+)
+
+// slugFunctions are the values of -slug flag, default one keeps the heading text with spaces replaced by dashes
+var slugFunctions = map[string]djot_parser.SlugFunc{
+	defaultSlug: nil,
+	"github":    djot_parser.GitHubSlug,
+	"ascii":     djot_parser.ASCIISlug,
+}
+
+// tableAlignments are the values of -table-align flag
+var tableAlignments = map[string]djot_parser.TableAlignment{
+	"style":  djot_parser.StyleAlignment,
+	"class":  djot_parser.ClassAlignment,
+	"column": djot_parser.ColumnAlignment,
+}
+
+func slugNames() []string {
+	names := make([]string, 0, len(slugFunctions))
+	for name := range slugFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func main() {
-	os.Exit(run())
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint":
+			os.Exit(runLint(os.Args[2:], os.Stdout))
+		case "serve":
+			os.Exit(runServe(os.Args[2:]))
+		case "lsp":
+			os.Exit(runLsp(os.Args[2:], os.Stdin, os.Stdout))
+		}
+	}
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout))
 }
 
-// TODO
-// - Files aren't cleaned up when errors are hit.
+// runLsp serves language server on stdin and stdout until the client exits
+func runLsp(args []string, stdin io.Reader, stdout io.Writer) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	slug := flags.String("slug", defaultSlug, "section ids of the headings: "+strings.Join(slugNames(), ", "))
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	slugFunc, ok := slugFunctions[*slug]
+	if !ok {
+		log.Printf("unknown section id style %v", *slug)
+		return 2
+	}
+	if err := djot_lsp.NewServerWithOptions(djot_parser.ParseOptions{Slug: slugFunc}).Serve(stdin, stdout); err != nil {
+		log.Printf("language server failed: %v", err)
+		return 1
+	}
+	return 0
+}
 
-func run() int {
+// run converts djot from the input files (stdin by default) to the output file (stdout by default); output file is
+// replaced only after the whole document is rendered, so failures leave previous output untouched
+func run(args []string, stdin io.Reader, stdout io.Writer) int {
+	flags := flag.NewFlagSet("djot", flag.ContinueOnError)
+	render := addRenderFlags(flags)
 	var (
-		from      = flag.String("from", "", "path to the input djot file (empty or '-' for stdin)")
-		to        = flag.String("to", "", "path to the output html file (empty or '-' for stdout)")
-		overwrite = flag.Bool("overwrite", false, "overwrite output html file")
-
-		in  io.Reader
-		out io.Writer
+		from       = flags.String("from", "", "path to the input djot file or directory (empty or '-' for stdin)")
+		to         = flags.String("to", "", "path to the output html file or directory (empty or '-' for stdout)")
+		overwrite  = flags.Bool("overwrite", false, "overwrite output html file")
+		toFormat   = flags.String("to-format", "html", "output format: html or term (ANSI terminal)")
+		width      = flags.Int("width", 0, "line width for term format (default $COLUMNS or 80)")
+		noColor    = flags.Bool("no-color", os.Getenv("NO_COLOR") != "", "disable colors, styles and hyperlinks in term format")
+		extension  = flags.String("ext", "", "extension of converted files in directory mode (default .html or .txt for term format)")
+		skip       = flags.String("skip", skipMtime, "skip unchanged files in directory mode: mtime (output is newer than input), hash (output content is the same) or none")
+		jobs       = flags.Int("jobs", runtime.NumCPU(), "number of files converted concurrently in directory mode")
+		watch      = flags.Bool("watch", false, "render input file again every time it changes")
+		interval   = flags.Duration("interval", 500*time.Millisecond, "polling interval of input changes in watch mode")
+		separate   = flags.Bool("separate", false, "render every input file separately into its own wrapper instead of joining them into one document")
+		dumpAst    = flags.Bool("ast", false, "write indented AST of the input instead of rendering it")
+		dumpRanges = flags.Bool("ranges", false, "add source ranges of the nodes to -ast output")
+		dumpTokens = flags.Bool("tokens", false, "write tokens of every input file as is (includes are not resolved) instead of rendering it")
 	)
-	flag.Parse()
+	sources, err := parseArgs(flags, args)
+	if err != nil {
+		return 2
+	}
+	if len(sources) > 0 && *from != "" {
+		log.Printf("input files must be given either with -from or as arguments")
+		return 2
+	}
+	if len(sources) == 0 {
+		sources = []string{*from}
+	}
 
-	if *from == "" || *from == "-" {
-		in = os.Stdin
-	} else {
-		f, err := os.Open(*from)
+	if *toFormat != "html" && *toFormat != djot_parser.TermFormat {
+		log.Printf("unsupported output format %v", *toFormat)
+		return 2
+	}
+	options, ok := render.options()
+	if !ok {
+		return 2
+	}
+	options.format, options.width, options.noColor = *toFormat, *width, *noColor
+	if *dumpRanges && !*dumpAst {
+		log.Printf("-ranges requires -ast")
+		return 2
+	}
+	if info, err := os.Stat(sources[0]); (*dumpAst || *dumpTokens) && (*watch || len(sources) == 1 && err == nil && info.IsDir()) {
+		log.Printf("-ast and -tokens are not supported in watch and directory modes")
+		return 2
+	}
+	if len(sources) == 1 && *watch {
+		return runWatch(sources[0], *to, options, *overwrite, *interval)
+	}
+	if info, err := os.Stat(sources[0]); len(sources) == 1 && err == nil && info.IsDir() {
+		return runBatch(sources[0], *to, batchOptions{
+			renderOptions: options,
+			extension:     *extension,
+			skip:          *skip,
+			jobs:          *jobs,
+			overwrite:     *overwrite,
+		})
+	}
+	if *watch {
+		log.Printf("watch mode supports only single input file")
+		return 1
+	}
+
+	if *separate && len(sources) < 2 {
+		log.Printf("-separate requires several input files")
+		return 2
+	}
+
+	inputs := make([][]byte, len(sources))
+	for i, source := range sources {
+		if source == "" || source == "-" {
+			inputs[i], err = io.ReadAll(stdin)
+		} else {
+			inputs[i], err = os.ReadFile(source)
+		}
 		if err != nil {
-			log.Printf("failed to open input file %v: %v", *from, err)
+			log.Printf("failed to read input file %v: %v", source, err)
 			return 1
 		}
-		in = f
-		defer f.Close()
 	}
-	if *to == "" || *to == "-" {
-		out = os.Stdout
-	} else {
-		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
-		if !*overwrite {
-			flags |= os.O_EXCL
+	var ast []djot_parser.TreeNode[djot_parser.DjotNode]
+	switch {
+	case *dumpTokens:
+	case *separate:
+		for i := range inputs {
+			nodes, err := buildInputs(sources[i:i+1], inputs[i:i+1], options.parse)
+			if err != nil {
+				log.Printf("failed to resolve input file %v: %v", sources[i], err)
+				return 1
+			}
+			// files are rendered on their own, so their ids get prefix to stay unique in the output
+			ast = append(ast, djot_parser.TreeNode[djot_parser.DjotNode]{
+				Type: djot_parser.DivNode,
+				Attributes: tokenizer.NewAttributes(
+					tokenizer.AttributeEntry{Key: djot_tokenizer.DjotAttributeClassKey, Value: fileClass},
+					tokenizer.AttributeEntry{Key: fileAttribute, Value: sources[i]},
+				),
+				Children: prefixIds(nodes, fmt.Sprintf("file%v-", i+1)),
+			})
 		}
-		f, err := os.OpenFile(*to, flags, 0640)
-		if err != nil {
-			log.Printf("failed to open output file %v: %v", *to, err)
+	default:
+		if ast, err = buildInputs(sources, inputs, options.parse); err != nil {
+			log.Printf("failed to resolve input files: %v", err)
 			return 1
 		}
-		defer f.Close()
-		out = f
 	}
-	input, err := io.ReadAll(in)
+	var output []byte
+	switch {
+	case *dumpTokens:
+		for _, input := range inputs {
+			output = append(output, djot_tokenizer.DumpDjotTokens(input, djot_tokenizer.BuildDjotTokens(input))...)
+		}
+	case *dumpAst:
+		for _, node := range ast {
+			if *dumpRanges {
+				output = append(output, node.StringWithRanges()...)
+			} else {
+				output = append(output, node.String()...)
+			}
+		}
+	default:
+		output = options.renderAst(ast)
+	}
+	if *to == "" || *to == "-" {
+		_, err = stdout.Write(output)
+	} else {
+		err = writeFileAtomic(*to, output, 0640, *overwrite)
+	}
 	if err != nil {
-		log.Printf("failed to read input file %v: %v", *from, err)
+		log.Printf("failed to write output file %v: %v", *to, err)
 		return 1
 	}
-	ast := djot_parser.BuildDjotAst(input)
-	context := djot_parser.NewConversionContext("html", djot_parser.DefaultConversionRegistry)
-	html := []byte(context.ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
-	for len(html) > 0 {
-		n, err := out.Write(html)
+	return 0
+}
+
+// buildInputs joins the inputs into one document and resolves includes relative to every input (standard input is
+// placed into the current directory); includes can't leave the common directory of all inputs
+func buildInputs(sources []string, inputs [][]byte, parse djot_parser.ParseOptions) ([]djot_parser.TreeNode[djot_parser.DjotNode], error) {
+	paths := make([]string, len(sources))
+	for i, source := range sources {
+		if source == "" {
+			source = "-"
+		}
+		path, err := filepath.Abs(source)
 		if err != nil {
-			log.Printf("failed to write output file %v: %v", *to, err)
-			return 1
+			return nil, err
+		}
+		paths[i] = path
+	}
+	root := filepath.Dir(paths[0])
+	for _, path := range paths {
+		for !isWithin(root, path) && filepath.Dir(root) != root {
+			root = filepath.Dir(root)
+		}
+	}
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil, err
 		}
-		html = html[n:]
+		names[i] = filepath.ToSlash(name)
 	}
+	ast, diagnostics := djot_parser.BuildJoinedDjotAstWithIncludes(os.DirFS(root), names, inputs, 0, parse)
+	logIncludeProblems(root, diagnostics)
+	return ast, nil
+}
 
-	return 0
+// isWithin reports whether path is the directory itself or is inside of it
+func isWithin(dir, path string) bool {
+	relative, err := filepath.Rel(dir, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+// logIncludeProblems logs failed includes, other diagnostics are reported by the linter; files are shown relative to
+// the current directory when it contains them
+func logIncludeProblems(root string, diagnostics []djot_parser.Diagnostic) {
+	cwd, _ := os.Getwd()
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Rule != djot_parser.IncludeRule {
+			continue
+		}
+		file := filepath.Join(root, filepath.FromSlash(diagnostic.File))
+		if relative, err := filepath.Rel(cwd, file); err == nil && isWithin(cwd, file) {
+			file = relative
+		}
+		log.Printf("%v: %v", file, diagnostic.Message)
+	}
+}
+
+// prefixIds adds prefix to ids of the nodes and to the links pointing into the same document
+func prefixIds(nodes []djot_parser.TreeNode[djot_parser.DjotNode], prefix string) []djot_parser.TreeNode[djot_parser.DjotNode] {
+	for i := range nodes {
+		if id, ok := nodes[i].Attributes.TryGet(djot_parser.IdKey); ok {
+			nodes[i].Attributes.Set(djot_parser.IdKey, prefix+id)
+		}
+		if href := nodes[i].Attributes.Get(djot_parser.LinkHrefKey); strings.HasPrefix(href, "#") {
+			nodes[i].Attributes.Set(djot_parser.LinkHrefKey, "#"+prefix+href[1:])
+		}
+		prefixIds(nodes[i].Children, prefix)
+	}
+	return nodes
+}
+
+// parseArgs parses flags placed anywhere between positional arguments (everything after "--" is positional) and
+// returns positional arguments
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		parsed := len(args) - flags.NArg()
+		if parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, flags.Args()...), nil
+		}
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+		positional, args = append(positional, flags.Arg(0)), flags.Args()[1:]
+	}
+}
+
+// renderFlags are the parse and html render flags shared by the converter and the preview server
+type renderFlags struct {
+	highlight, mathML, admonitions, noSmart, crossRefs, sections *bool
+	quotes, slug, alignment                                      *string
+}
+
+func addRenderFlags(flags *flag.FlagSet) renderFlags {
+	return renderFlags{
+		highlight:   flags.Bool("highlight", false, "highlight code blocks with the built-in lexers (html format)"),
+		mathML:      flags.Bool("mathml", false, "render supported TeX math as MathML (html format)"),
+		admonitions: flags.Bool("admonitions", false, "render note, tip, warning and danger divs as callouts"),
+		quotes:      flags.String("quotes", "", "locale of the quote style, like de or fr-CH (default en)"),
+		noSmart:     flags.Bool("no-smart-punctuation", false, "leave quotes, dashes and ellipsis as typed"),
+		slug:        flags.String("slug", defaultSlug, "section ids of the headings: "+strings.Join(slugNames(), ", ")),
+		crossRefs:   flags.Bool("crossref", false, "number figures, tables and sections and fill empty links to them with the numbers"),
+		sections:    flags.Bool("table-sections", false, "write leading header rows of the tables into <thead> and other rows into <tbody> (html format)"),
+		alignment:   flags.String("table-align", "style", "column alignment of the tables: style (inline style), class (align-* class of the cells) or column (<col> classes) (html format)"),
+	}
+}
+
+// options returns html render options of the parsed flags, invalid values are logged
+func (f renderFlags) options() (renderOptions, bool) {
+	parse := djot_parser.ParseOptions{NoSmartPunctuation: *f.noSmart}
+	if *f.quotes != "" {
+		style, ok := djot_parser.QuoteStyleForLocale(*f.quotes)
+		if !ok {
+			log.Printf("unknown quote style locale %v", *f.quotes)
+			return renderOptions{}, false
+		}
+		parse.Quotes = style
+	}
+	slugFunc, ok := slugFunctions[*f.slug]
+	if !ok {
+		log.Printf("unknown section id style %v", *f.slug)
+		return renderOptions{}, false
+	}
+	parse.Slug = slugFunc
+	if *f.crossRefs {
+		labels := djot_parser.DefaultCrossReferenceLabels
+		parse.CrossReferences = &labels
+	}
+	alignment, ok := tableAlignments[*f.alignment]
+	if !ok {
+		log.Printf("unknown table alignment %v", *f.alignment)
+		return renderOptions{}, false
+	}
+	return renderOptions{
+		parse:       parse,
+		format:      "html",
+		highlight:   *f.highlight,
+		mathML:      *f.mathML,
+		admonitions: *f.admonitions,
+		tables:      djot_parser.TableOptions{Sections: *f.sections, Alignment: alignment},
+	}, true
+}
+
+type renderOptions struct {
+	parse       djot_parser.ParseOptions
+	format      string
+	width       int
+	highlight   bool
+	mathML      bool
+	noColor     bool
+	admonitions bool
+	tables      djot_parser.TableOptions
+}
+
+// render converts the document with its includes, name places the document in the file system
+func (o renderOptions) render(fsys fs.FS, name string, document []byte) ([]byte, []djot_parser.Diagnostic) {
+	ast, diagnostics := djot_parser.BuildJoinedDjotAstWithIncludes(fsys, []string{name}, [][]byte{document}, 0, o.parse)
+	return o.renderAst(ast), diagnostics
+}
+
+func (o renderOptions) renderAst(ast []djot_parser.TreeNode[djot_parser.DjotNode]) []byte {
+	var admonitions djot_parser.Admonitions
+	if o.admonitions {
+		admonitions = djot_parser.DefaultAdmonitions
+	}
+	if o.format == djot_parser.TermFormat {
+		options := djot_parser.TermOptions{Width: o.width, NoColor: o.noColor, Admonitions: admonitions}
+		if options.Width <= 0 {
+			options.Width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+		}
+		return []byte(djot_parser.ConvertDjotToTerm(options, ast...))
+	}
+	context := djot_parser.NewConversionContext("html", djot_parser.DefaultConversionRegistry)
+	if o.highlight {
+		context.Options.Highlighter = highlight.Default
+	}
+	if o.mathML {
+		context.Options.Math = mathml.Convert
+	}
+	context.Options.Admonitions = admonitions
+	context.Options.Tables = o.tables
+	return []byte(context.ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
+}
+
+// writeFileAtomic writes content into temporary file in the same directory and moves it into place, so readers never
+// see partial output and previous content is kept on failure; existing file isn't replaced unless overwrite is set
+func writeFileAtomic(path string, content []byte, mode os.FileMode, overwrite bool) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if removeErr := os.Remove(f.Name()); err == nil && removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			err = removeErr
+		}
+	}()
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if overwrite {
+		return os.Rename(f.Name(), path)
+	}
+	// link fails if path already exists, temporary file is removed afterwards
+	return os.Link(f.Name(), path)
 }
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"md0.org/djot/internal/testx"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	testx.AssertNilError(t, "", err)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestRun(t *testing.T) {
+	t.Run("stdin to stdout", func(t *testing.T) {
+		var stdout bytes.Buffer
+		testx.AssertEqual(t, "", 0, run(nil, strings.NewReader("*a*"), &stdout))
+		testx.AssertEqual(t, "", "<p><strong>a</strong></p>\n", stdout.String())
+	})
+	t.Run("file to file", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"doc.djot": "_a_\n"})
+		from, to := filepath.Join(dir, "doc.djot"), filepath.Join(dir, "doc.html")
+		testx.AssertEqual(t, "", 0, run([]string{"-from", from, "-to", to}, nil, nil))
+		testx.AssertEqual(t, "", "<p><em>a</em></p>\n", readFile(t, to))
+		info, err := os.Stat(to)
+		testx.AssertNilError(t, "", err)
+		testx.AssertEqual(t, "", os.FileMode(0640), info.Mode().Perm())
+	})
+	t.Run("existing output requires overwrite", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"doc.djot": "new\n", "doc.html": "old"})
+		from, to := filepath.Join(dir, "doc.djot"), filepath.Join(dir, "doc.html")
+		testx.AssertEqual(t, "", 1, run([]string{"-from", from, "-to", to}, nil, nil))
+		testx.AssertEqual(t, "", "old", readFile(t, to))
+		testx.AssertEqual(t, "", 0, run([]string{"-from", from, "-to", to, "-overwrite"}, nil, nil))
+		testx.AssertEqual(t, "", "<p>new</p>\n", readFile(t, to))
+		testx.AssertEqual(t, "", []string{"doc.djot", "doc.html"}, listDir(t, dir))
+	})
+	t.Run("failed read keeps output", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"doc.html": "old"})
+		to := filepath.Join(dir, "doc.html")
+		testx.AssertEqual(t, "", 1, run([]string{"-from", filepath.Join(dir, "missing.djot"), "-to", to, "-overwrite"}, nil, nil))
+		testx.AssertEqual(t, "", 1, run([]string{"-to", to, "-overwrite"}, failingReader{}, nil))
+		testx.AssertEqual(t, "", "old", readFile(t, to))
+		testx.AssertEqual(t, "", []string{"doc.html"}, listDir(t, dir))
+	})
+	t.Run("failed write leaves nothing", func(t *testing.T) {
+		dir := t.TempDir()
+		testx.AssertEqual(t, "", 1, run([]string{"-to", filepath.Join(dir, "missing", "doc.html")}, strings.NewReader("a"), nil))
+		testx.AssertEqual(t, "", []string{}, listDir(t, dir))
+		testx.AssertNilError(t, "", os.Mkdir(filepath.Join(dir, "doc.html"), 0750))
+		testx.AssertEqual(t, "", 1, run([]string{"-to", filepath.Join(dir, "doc.html"), "-overwrite"}, strings.NewReader("a"), nil))
+		testx.AssertEqual(t, "", []string{"doc.html"}, listDir(t, dir))
+	})
+	t.Run("multiple inputs", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"1.djot": "# A\n\nx[^n]\n\n[^n]: one\n", "2.djot": "# A\n\ny[^n2]\n\n[^n2]: two\n"})
+		first, second := filepath.Join(dir, "1.djot"), filepath.Join(dir, "2.djot")
+		var stdout bytes.Buffer
+		testx.AssertEqual(t, "", 0, run([]string{first, "-to", "-", second}, nil, &stdout))
+		joined := stdout.String()
+		testx.AssertTrue(t, "", strings.Contains(joined, `<section id="A-1">`))
+		testx.AssertTrue(t, "", strings.Contains(joined, `<li id="fn2">`))
+		testx.AssertEqual(t, "", 1, strings.Count(joined, `role="doc-endnotes"`))
+
+		stdout.Reset()
+		testx.AssertEqual(t, "", 0, run([]string{"-separate", first, second}, nil, &stdout))
+		separate := stdout.String()
+		testx.AssertTrue(t, "", strings.HasPrefix(separate, `<div class="djot-file" data-file="`+first+`">`))
+		testx.AssertEqual(t, "", 2, strings.Count(separate, `role="doc-endnotes"`))
+		for _, id := range []string{`id="file1-A"`, `id="file2-A"`, `id="file1-fn1"`, `id="file2-fn1"`, `href="#file2-fnref1"`} {
+			testx.AssertEqual(t, id, 1, strings.Count(separate, id))
+		}
+		testx.AssertEqual(t, "", 2, run([]string{"-separate", first}, nil, nil))
+
+		testx.AssertEqual(t, "", 2, run([]string{"-from", first, second}, nil, nil))
+		testx.AssertEqual(t, "", 1, run([]string{first, filepath.Join(dir, "missing.djot")}, nil, nil))
+	})
+	t.Run("parse options", func(t *testing.T) {
+		var stdout bytes.Buffer
+		testx.AssertEqual(t, "", 0, run([]string{"-quotes", "de", "-slug", "github"}, strings.NewReader("# Über Uns\n\n\"a\""), &stdout))
+		testx.AssertEqual(t, "", "<section id=\"über-uns\">\n<h1>Über Uns</h1>\n<p>„a&ldquo;</p>\n</section>\n", stdout.String())
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"1.djot": "\"a\" -- b\n", "2.djot": "# Über Uns\n"})
+		stdout.Reset()
+		testx.AssertEqual(t, "", 0, run([]string{"-no-smart-punctuation", filepath.Join(dir, "1.djot")}, nil, &stdout))
+		testx.AssertEqual(t, "", "<p>\"a\" -- b</p>\n", stdout.String())
+		stdout.Reset()
+		testx.AssertEqual(t, "", 0, run([]string{"-slug", "ascii", filepath.Join(dir, "1.djot"), filepath.Join(dir, "2.djot")}, nil, &stdout))
+		testx.AssertTrue(t, "", strings.Contains(stdout.String(), `<section id="uber-uns">`))
+		stdout.Reset()
+		testx.AssertEqual(t, "", 0, run([]string{"-crossref", "-slug", "ascii"}, strings.NewReader("# Über Uns\n\nSee [](#uber-uns).\n"), &stdout))
+		testx.AssertTrue(t, "", strings.Contains(stdout.String(), `<a href="#uber-uns">Section 1</a>`))
+		testx.AssertEqual(t, "", 2, run([]string{"-quotes", "xx"}, strings.NewReader(""), nil))
+		testx.AssertEqual(t, "", 2, run([]string{"-slug", "nope"}, strings.NewReader(""), nil))
+	})
+	t.Run("table options", func(t *testing.T) {
+		var stdout bytes.Buffer
+		testx.AssertEqual(t, "", 0, run([]string{"-table-sections", "-table-align", "class"}, strings.NewReader("| a |\n|--:|\n| 1 |\n"), &stdout))
+		testx.AssertEqual(t, "", `<table>
+<thead>
+<tr>
+<th class="align-right">a</th>
+</tr>
+</thead>
+<tbody>
+<tr>
+<td class="align-right">1</td>
+</tr>
+</tbody>
+</table>
+`, stdout.String())
+		testx.AssertEqual(t, "", 2, run([]string{"-table-align", "left"}, strings.NewReader(""), nil))
+	})
+	t.Run("includes", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"doc.djot":        "{include=\"parts/note.djot\"}\n:::\n:::\n",
+			"parts/note.djot": "{include=\"../doc.djot\"}\n:::\n:::\n\n_note_\n",
+		})
+		var stdout bytes.Buffer
+		testx.AssertEqual(t, "", 0, run([]string{filepath.Join(dir, "doc.djot")}, nil, &stdout))
+		testx.AssertEqual(t, "", "<div>\n</div>\n<p><em>note</em></p>\n", stdout.String())
+
+		writeFiles(t, dir, map[string]string{
+			"a/doc.djot":     "{include=\"parts/w.djot\"}\n:::\n:::\n",
+			"a/parts/w.djot": "_warn_\n",
+			"b/doc.djot":     "{include=\"parts/w.djot\"}\n:::\n:::\n",
+			"b/parts/w.djot": "_b_\n",
+		})
+		first, second := filepath.Join(dir, "a", "doc.djot"), filepath.Join(dir, "b", "doc.djot")
+		stdout.Reset()
+		testx.AssertEqual(t, "", 0, run([]string{first, second}, nil, &stdout))
+		testx.AssertEqual(t, "", "<p><em>warn</em></p>\n<p><em>b</em></p>\n", stdout.String())
+		stdout.Reset()
+		testx.AssertEqual(t, "", 0, run([]string{"-separate", first, second}, nil, &stdout))
+		testx.AssertTrue(t, "", strings.Contains(stdout.String(), "<p><em>warn</em></p>\n</div>\n"))
+		testx.AssertTrue(t, "", strings.Contains(stdout.String(), "<p><em>b</em></p>\n</div>\n"))
+
+		// standard input resolves includes relative to the current directory
+		cwd, err := os.Getwd()
+		testx.AssertNilError(t, "", err)
+		testx.AssertNilError(t, "", os.Chdir(filepath.Join(dir, "a")))
+		defer func() { testx.AssertNilError(t, "", os.Chdir(cwd)) }()
+		stdout.Reset()
+		testx.AssertEqual(t, "", 0, run(nil, strings.NewReader("{include=\"parts/w.djot\"}\n:::\n:::\n"), &stdout))
+		testx.AssertEqual(t, "", "<p><em>warn</em></p>\n", stdout.String())
+	})
+	t.Run("dump", func(t *testing.T) {
+		var stdout bytes.Buffer
+		testx.AssertEqual(t, "", 0, run([]string{"-ast"}, strings.NewReader("[a]{.b}"), &stdout))
+		testx.AssertEqual(t, "", "DocumentNode\n  ParagraphNode\n    SpanNode class=\"b\"\n      TextNode \"a\"\n", stdout.String())
+		stdout.Reset()
+		testx.AssertEqual(t, "", 0, run([]string{"-tokens"}, strings.NewReader("_a_"), &stdout))
+		testx.AssertEqual(t, "", `0 DocumentBlock 0-0 -> 6
+1   ParagraphBlock 0-0 -> 5
+2     EmphasisInline 0-1 "_" -> 4
+3       None 1-2 "a"
+4     EmphasisInlineClose 2-3 "_" <- 2
+5   ParagraphBlockClose 3-3 <- 1
+6 DocumentBlockClose 3-3 <- 0
+`, stdout.String())
+		stdout.Reset()
+		testx.AssertEqual(t, "", 0, run([]string{"-ast", "-ranges"}, strings.NewReader("# A\n\n_a_ b\n"), &stdout))
+		testx.AssertEqual(t, "", `DocumentNode 0-11
+  SectionNode id="A"
+    HeadingNode 0-4 $HeadingLevelKey="#"
+      TextNode 2-3 "A"
+    ParagraphNode 5-11
+      EmphasisNode 5-8
+        TextNode 6-7 "a"
+      TextNode 8-10 " b"
+`, stdout.String())
+		testx.AssertEqual(t, "", 2, run([]string{"-ranges"}, strings.NewReader("a"), &stdout))
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "doc.djot")
+		testx.AssertNilError(t, "", os.WriteFile(input, []byte("a"), 0640))
+		testx.AssertEqual(t, "", 2, run([]string{"-ast", "-watch", input}, nil, &stdout))
+		testx.AssertEqual(t, "", 2, run([]string{"-tokens", "-to", filepath.Join(dir, "out"), dir}, nil, &stdout))
+	})
+	t.Run("parse arguments", func(t *testing.T) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		to := flags.String("to", "", "")
+		positional, err := parseArgs(flags, []string{"a", "-to", "x", "b", "--", "-c"})
+		testx.AssertNilError(t, "", err)
+		testx.AssertEqual(t, "", []string{"a", "b", "-c"}, positional)
+		testx.AssertEqual(t, "", "x", *to)
+	})
+	t.Run("invalid arguments", func(t *testing.T) {
+		testx.AssertEqual(t, "", 2, run([]string{"-unknown"}, nil, nil))
+		testx.AssertEqual(t, "", 2, run([]string{"-to-format", "pdf"}, nil, nil))
+		testx.AssertEqual(t, "", 2, runServe([]string{"-quotes", "xx"}))
+		testx.AssertEqual(t, "", 2, runLsp([]string{"-slug", "nope"}, nil, nil))
+		testx.AssertEqual(t, "", 0, runLsp([]string{"-slug", "ascii"}, strings.NewReader(""), io.Discard))
+	})
+}
@@ -0,0 +1,458 @@
+package mathml
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Convert translates a practical subset of TeX math into MathML; error means that input is not supported and caller
+// should fall back to the raw TeX
+func Convert(tex string, display bool) (string, error) {
+	p := parser{tex: tex, tokens: lex(tex)}
+	row, err := p.parseRow(display, "")
+	if err != nil {
+		return "", err
+	}
+	if t, ok := p.peek(); ok {
+		return "", fmt.Errorf("unexpected %q", t.Value)
+	}
+	var builder strings.Builder
+	builder.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		builder.WriteString(` display="block"`)
+	}
+	builder.WriteString("><semantics><mrow>")
+	builder.WriteString(row)
+	builder.WriteString(`</mrow><annotation encoding="application/x-tex">`)
+	builder.WriteString(escape(strings.TrimSpace(tex)))
+	builder.WriteString("</annotation></semantics></math>")
+	return builder.String(), nil
+}
+
+type tokenType int
+
+const (
+	commandToken tokenType = iota
+	letterToken
+	numberToken
+	symbolToken
+)
+
+type token struct {
+	Type       tokenType
+	Value      string
+	Start, End int // byte range of the token in the source
+}
+
+func lex(tex string) []token {
+	tokens := make([]token, 0)
+	for i := 0; i < len(tex); {
+		c, size := utf8.DecodeRuneInString(tex[i:])
+		switch {
+		case unicode.IsSpace(c):
+			i += size
+		case c == '\\':
+			j := i + 1
+			for j < len(tex) && isAsciiLetter(tex[j]) {
+				j++
+			}
+			if j == i+1 && j < len(tex) {
+				_, size = utf8.DecodeRuneInString(tex[j:])
+				j += size
+			}
+			tokens = append(tokens, token{Type: commandToken, Value: tex[i+1 : j], Start: i, End: j})
+			i = j
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(tex) && tex[i+1] >= '0' && tex[i+1] <= '9':
+			j := i + 1
+			for j < len(tex) && (tex[j] >= '0' && tex[j] <= '9' || tex[j] == '.' && j+1 < len(tex) && tex[j+1] >= '0' && tex[j+1] <= '9') {
+				j++
+			}
+			tokens = append(tokens, token{Type: numberToken, Value: tex[i:j], Start: i, End: j})
+			i = j
+		case unicode.IsLetter(c):
+			tokens = append(tokens, token{Type: letterToken, Value: string(c), Start: i, End: i + size})
+			i += size
+		default:
+			tokens = append(tokens, token{Type: symbolToken, Value: string(c), Start: i, End: i + size})
+			i += size
+		}
+	}
+	return tokens
+}
+
+func isAsciiLetter(b byte) bool { return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' }
+
+type parser struct {
+	tex     string
+	tokens  []token
+	pos     int
+	variant string
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return token{}, false
+}
+
+func (p *parser) next() (token, bool) {
+	t, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return t, ok
+}
+
+func (p *parser) expect(t tokenType, value string) error {
+	if current, ok := p.next(); !ok || current.Type != t || current.Value != value {
+		return fmt.Errorf("expected %q at token %d", value, p.pos)
+	}
+	return nil
+}
+
+func isRowEnd(t token, stop string) bool {
+	switch {
+	case t.Type == symbolToken && (t.Value == "}" || t.Value == "&"):
+		return true
+	case t.Type == commandToken && (t.Value == "right" || t.Value == "end" || t.Value == "\\"):
+		return true
+	}
+	return stop != "" && t.Type == symbolToken && t.Value == stop
+}
+
+// parseRow parses atoms with optional scripts until the end of input or the group terminator
+func (p *parser) parseRow(display bool, stop string) (string, error) {
+	var builder strings.Builder
+	for {
+		t, ok := p.peek()
+		if !ok || isRowEnd(t, stop) {
+			return builder.String(), nil
+		}
+		atom, err := p.parseScripted(display)
+		if err != nil {
+			return "", err
+		}
+		builder.WriteString(atom)
+	}
+}
+
+func (p *parser) parseScripted(display bool) (string, error) {
+	start := p.pos
+	base, err := p.parseAtom(display)
+	if err != nil {
+		return "", err
+	}
+	var sub, sup string
+	hasSub, hasSup := false, false
+	for {
+		t, ok := p.peek()
+		if !ok || t.Type != symbolToken || (t.Value != "^" && t.Value != "_") {
+			break
+		}
+		p.pos++
+		script, err := p.parseArgument(false)
+		if err != nil {
+			return "", err
+		}
+		if t.Value == "^" {
+			if hasSup {
+				return "", fmt.Errorf("double superscript")
+			}
+			sup, hasSup = script, true
+		} else {
+			if hasSub {
+				return "", fmt.Errorf("double subscript")
+			}
+			sub, hasSub = script, true
+		}
+	}
+	under := display && p.tokens[start].Type == commandToken && limitCommands[p.tokens[start].Value]
+	switch {
+	case hasSub && hasSup && under:
+		return "<munderover>" + base + sub + sup + "</munderover>", nil
+	case hasSub && hasSup:
+		return "<msubsup>" + base + sub + sup + "</msubsup>", nil
+	case hasSub && under:
+		return "<munder>" + base + sub + "</munder>", nil
+	case hasSub:
+		return "<msub>" + base + sub + "</msub>", nil
+	case hasSup && under:
+		return "<mover>" + base + sup + "</mover>", nil
+	case hasSup:
+		return "<msup>" + base + sup + "</msup>", nil
+	}
+	return base, nil
+}
+
+// parseArgument parses command argument or script: either group in braces or a single atom
+func (p *parser) parseArgument(display bool) (string, error) {
+	t, ok := p.peek()
+	if !ok {
+		return "", fmt.Errorf("missing argument")
+	}
+	if t.Type == symbolToken && t.Value == "{" {
+		return p.parseAtom(display)
+	}
+	if t.Type == numberToken && len(t.Value) > 1 {
+		// only the first digit is an argument in TeX: x^12 is x^{1}2
+		p.tokens[p.pos].Value, p.tokens[p.pos].Start = t.Value[1:], t.Start+1
+		return "<mn>" + escape(t.Value[:1]) + "</mn>", nil
+	}
+	return p.parseAtom(display)
+}
+
+// parseGroupText returns source of the group in braces as is, including whitespace
+func (p *parser) parseGroupText() (string, error) {
+	if err := p.expect(symbolToken, "{"); err != nil {
+		return "", err
+	}
+	start := p.tokens[p.pos-1].End
+	for depth := 1; ; {
+		t, ok := p.next()
+		if !ok {
+			return "", fmt.Errorf("unclosed group")
+		}
+		if t.Type == symbolToken && t.Value == "{" {
+			depth++
+		} else if t.Type == symbolToken && t.Value == "}" {
+			if depth--; depth == 0 {
+				return p.tex[start:t.Start], nil
+			}
+		}
+	}
+}
+
+func (p *parser) identifier(text string) string {
+	if p.variant != "" {
+		return `<mi mathvariant="` + p.variant + `">` + escape(text) + "</mi>"
+	}
+	return "<mi>" + escape(text) + "</mi>"
+}
+
+func (p *parser) parseAtom(display bool) (string, error) {
+	t, ok := p.next()
+	if !ok {
+		return "", fmt.Errorf("unexpected end of input")
+	}
+	switch t.Type {
+	case letterToken:
+		return p.identifier(t.Value), nil
+	case numberToken:
+		return "<mn>" + escape(t.Value) + "</mn>", nil
+	case symbolToken:
+		switch t.Value {
+		case "{":
+			row, err := p.parseRow(display, "}")
+			if err != nil {
+				return "", err
+			}
+			if err = p.expect(symbolToken, "}"); err != nil {
+				return "", err
+			}
+			return "<mrow>" + row + "</mrow>", nil
+		case "}", "^", "_", "&", "#", "$", "%", "~":
+			return "", fmt.Errorf("unexpected %q", t.Value)
+		case "-":
+			return "<mo>−</mo>", nil
+		case "'":
+			return "<mo>′</mo>", nil
+		case "*":
+			return "<mo>∗</mo>", nil
+		}
+		return "<mo>" + escape(t.Value) + "</mo>", nil
+	}
+	return p.parseCommand(t.Value, display)
+}
+
+func (p *parser) parseCommand(name string, display bool) (string, error) {
+	if symbol, ok := identifiers[name]; ok {
+		return p.identifier(symbol), nil
+	}
+	if symbol, ok := operators[name]; ok {
+		return "<mo>" + escape(symbol) + "</mo>", nil
+	}
+	if symbol, ok := largeOperators[name]; ok {
+		return `<mo largeop="true" movablelimits="true">` + symbol + "</mo>", nil
+	}
+	if functionNames[name] {
+		return "<mi>" + name + "</mi>", nil
+	}
+	if width, ok := spaces[name]; ok {
+		return `<mspace width="` + width + `"/>`, nil
+	}
+	if accent, ok := accents[name]; ok {
+		argument, err := p.parseArgument(display)
+		if err != nil {
+			return "", err
+		}
+		if name == "underline" {
+			return "<munder>" + argument + `<mo stretchy="true">` + accent + "</mo></munder>", nil
+		}
+		return `<mover accent="true">` + argument + `<mo stretchy="true">` + accent + "</mo></mover>", nil
+	}
+	if variant, ok := variants[name]; ok {
+		previous := p.variant
+		p.variant = variant
+		argument, err := p.parseArgument(display)
+		p.variant = previous
+		return argument, err
+	}
+	switch name {
+	case "frac", "dfrac", "tfrac", "binom":
+		numerator, err := p.parseArgument(display)
+		if err != nil {
+			return "", err
+		}
+		denominator, err := p.parseArgument(display)
+		if err != nil {
+			return "", err
+		}
+		if name == "binom" {
+			return `<mrow><mo>(</mo><mfrac linethickness="0">` + numerator + denominator + `</mfrac><mo>)</mo></mrow>`, nil
+		}
+		return "<mfrac>" + numerator + denominator + "</mfrac>", nil
+	case "sqrt":
+		var index string
+		if t, ok := p.peek(); ok && t.Type == symbolToken && t.Value == "[" {
+			p.pos++
+			row, err := p.parseRow(display, "]")
+			if err != nil {
+				return "", err
+			}
+			if err = p.expect(symbolToken, "]"); err != nil {
+				return "", err
+			}
+			index = "<mrow>" + row + "</mrow>"
+		}
+		radicand, err := p.parseArgument(display)
+		if err != nil {
+			return "", err
+		}
+		if index != "" {
+			return "<mroot>" + radicand + index + "</mroot>", nil
+		}
+		return "<msqrt>" + radicand + "</msqrt>", nil
+	case "text", "mbox":
+		text, err := p.parseGroupText()
+		if err != nil {
+			return "", err
+		}
+		return "<mtext>" + escape(text) + "</mtext>", nil
+	case "operatorname":
+		text, err := p.parseGroupText()
+		if err != nil {
+			return "", err
+		}
+		return "<mi>" + escape(text) + "</mi>", nil
+	case "left":
+		return p.parseFenced(display)
+	case "begin":
+		return p.parseEnvironment(display)
+	}
+	if len(name) == 1 && !isAsciiLetter(name[0]) {
+		// escaped symbols like \{ or \%
+		return "<mo>" + escape(name) + "</mo>", nil
+	}
+	return "", fmt.Errorf("unsupported command \\%v", name)
+}
+
+func (p *parser) parseDelimiter() (string, error) {
+	t, ok := p.next()
+	if !ok {
+		return "", fmt.Errorf("missing delimiter")
+	}
+	if t.Type == symbolToken && strings.Contains("()[]|/.", t.Value) {
+		if t.Value == "." {
+			return "", nil
+		}
+		return t.Value, nil
+	}
+	if t.Type == commandToken {
+		if delimiter, ok := delimiters[t.Value]; ok {
+			return delimiter, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported delimiter %q", t.Value)
+}
+
+func fence(delimiter string) string {
+	if delimiter == "" {
+		return ""
+	}
+	return `<mo fence="true" stretchy="true">` + escape(delimiter) + "</mo>"
+}
+
+func (p *parser) parseFenced(display bool) (string, error) {
+	open, err := p.parseDelimiter()
+	if err != nil {
+		return "", err
+	}
+	row, err := p.parseRow(display, "")
+	if err != nil {
+		return "", err
+	}
+	if err = p.expect(commandToken, "right"); err != nil {
+		return "", err
+	}
+	closing, err := p.parseDelimiter()
+	if err != nil {
+		return "", err
+	}
+	return "<mrow>" + fence(open) + row + fence(closing) + "</mrow>", nil
+}
+
+func (p *parser) parseEnvironment(display bool) (string, error) {
+	name, err := p.parseGroupText()
+	if err != nil {
+		return "", err
+	}
+	delimiters, ok := environments[name]
+	if !ok {
+		return "", fmt.Errorf("unsupported environment %v", name)
+	}
+	var table strings.Builder
+	table.WriteString("<mtable>")
+	for {
+		table.WriteString("<mtr>")
+		for {
+			cell, err := p.parseRow(display, "")
+			if err != nil {
+				return "", err
+			}
+			table.WriteString("<mtd>" + cell + "</mtd>")
+			if t, ok := p.peek(); ok && t.Type == symbolToken && t.Value == "&" {
+				p.pos++
+				continue
+			}
+			break
+		}
+		table.WriteString("</mtr>")
+		t, ok := p.next()
+		if !ok {
+			return "", fmt.Errorf("unclosed environment %v", name)
+		}
+		if t.Type == commandToken && t.Value == "\\" {
+			continue
+		}
+		if t.Type == commandToken && t.Value == "end" {
+			end, err := p.parseGroupText()
+			if err != nil {
+				return "", err
+			}
+			if end != name {
+				return "", fmt.Errorf("environment %v closed with %v", name, end)
+			}
+			break
+		}
+		return "", fmt.Errorf("unexpected %q in environment %v", t.Value, name)
+	}
+	table.WriteString("</mtable>")
+	return "<mrow>" + fence(delimiters[0]) + table.String() + fence(delimiters[1]) + "</mrow>", nil
+}
+
+var xmlReplacer = strings.NewReplacer(`&`, "&amp;", `<`, "&lt;", `>`, "&gt;", `"`, "&quot;")
+
+func escape(text string) string { return xmlReplacer.Replace(text) }
@@ -0,0 +1,66 @@
+package mathml
+
+import (
+	"strings"
+	"testing"
+
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/html_writer"
+	"md0.org/djot/internal/testx"
+)
+
+func body(t *testing.T, tex string, display bool) string {
+	result, err := Convert(tex, display)
+	testx.AssertEqual(t, "", nil, err)
+	start, end := strings.Index(result, "<semantics><mrow>"), strings.Index(result, "</mrow><annotation")
+	return result[start+len("<semantics><mrow>") : end]
+}
+
+func TestConvert(t *testing.T) {
+	for _, tt := range []struct {
+		tex    string
+		mathml string
+	}{
+		{tex: `x^2 + 1`, mathml: `<msup><mi>x</mi><mn>2</mn></msup><mo>+</mo><mn>1</mn>`},
+		{tex: `a_{ij}^{n}`, mathml: `<msubsup><mi>a</mi><mrow><mi>i</mi><mi>j</mi></mrow><mrow><mi>n</mi></mrow></msubsup>`},
+		{tex: `x^12`, mathml: `<msup><mi>x</mi><mn>1</mn></msup><mn>2</mn>`},
+		{tex: `\frac{\alpha}{2\pi}`, mathml: `<mfrac><mrow><mi>α</mi></mrow><mrow><mn>2</mn><mi>π</mi></mrow></mfrac>`},
+		{tex: `\sqrt[3]{x} \leq y`, mathml: `<mroot><mrow><mi>x</mi></mrow><mrow><mn>3</mn></mrow></mroot><mo>≤</mo><mi>y</mi>`},
+		{tex: `a < b - c`, mathml: `<mi>a</mi><mo>&lt;</mo><mi>b</mi><mo>−</mo><mi>c</mi>`},
+		{tex: `\sin x \cdot \text{if } x`, mathml: `<mi>sin</mi><mi>x</mi><mo>⋅</mo><mtext>if </mtext><mi>x</mi>`},
+		{tex: `\mathbf{v}`, mathml: `<mrow><mi mathvariant="bold">v</mi></mrow>`},
+		{tex: `\left( x \right.`, mathml: `<mrow><mo fence="true" stretchy="true">(</mo><mi>x</mi></mrow>`},
+		{
+			tex:    `\begin{pmatrix} 1 & 0 \\ 0 & 1 \end{pmatrix}`,
+			mathml: `<mrow><mo fence="true" stretchy="true">(</mo><mtable><mtr><mtd><mn>1</mn></mtd><mtd><mn>0</mn></mtd></mtr><mtr><mtd><mn>0</mn></mtd><mtd><mn>1</mn></mtd></mtr></mtable><mo fence="true" stretchy="true">)</mo></mrow>`,
+		},
+	} {
+		t.Run(tt.tex, func(t *testing.T) {
+			testx.AssertEqual(t, "", tt.mathml, body(t, tt.tex, false))
+		})
+	}
+	t.Run("limits in display mode", func(t *testing.T) {
+		testx.AssertEqual(t, "", `<munderover><mo largeop="true" movablelimits="true">∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover>`, body(t, `\sum_{i=1}^n`, true))
+		testx.AssertEqual(t, "", `<msubsup><mo largeop="true" movablelimits="true">∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></msubsup>`, body(t, `\sum_{i=1}^n`, false))
+	})
+	t.Run("document", func(t *testing.T) {
+		result, err := Convert(`a<b`, true)
+		testx.AssertEqual(t, "", nil, err)
+		testx.AssertEqual(t, "", `<math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><semantics><mrow><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow><annotation encoding="application/x-tex">a&lt;b</annotation></semantics></math>`, result)
+	})
+	for _, tex := range []string{`\unknown{x}`, `\frac{a}`, `{x`, `x}`, `x^`, `x^1^2`, `\begin{foo}x\end{foo}`, `\begin{matrix}x\end{pmatrix}`, `\left( x`} {
+		t.Run("unsupported "+tex, func(t *testing.T) {
+			_, err := Convert(tex, false)
+			testx.AssertTrue(t, "", err != nil)
+		})
+	}
+}
+
+func TestMathConversion(t *testing.T) {
+	ast := djot_parser.BuildDjotAst([]byte("$`x^2` and $`\\unknown`"))
+	context := djot_parser.NewConversionContext("html")
+	context.Options.Math = Convert
+	testx.AssertEqual(t, "", `<p><span class="math inline"><math xmlns="http://www.w3.org/1998/Math/MathML"><semantics><mrow>`+
+		`<msup><mi>x</mi><mn>2</mn></msup></mrow><annotation encoding="application/x-tex">x^2</annotation></semantics></math></span>`+
+		` and <span class="math inline">\(\unknown\)</span></p>`+"\n", context.ConvertDjotToHtml(&html_writer.HtmlWriter{}, ast...))
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html"
+	"io/fs"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/html_writer"
+	"md0.org/djot/lint"
+)
+
+const (
+	eventsPath = "/_djot/events"
+	indexFile  = "index" + lint.Extension
+	// reloadScript reconnects automatically after server restarts, so page is reloaded only on change events
+	reloadScript = `<script>new EventSource("` + eventsPath + `").onmessage = function() { location.reload() }</script>`
+)
+
+type (
+	fileState struct {
+		modTime int64
+		size    int64
+	}
+	// previewServer renders djot files of the directory and notifies open pages about changes with Server-Sent Events
+	previewServer struct {
+		fsys        fs.FS
+		options     renderOptions
+		static      http.Handler
+		mutex       sync.Mutex
+		subscribers map[chan struct{}]bool
+		files       map[string]fileState
+	}
+)
+
+// runServe serves directory (current directory by default) for live preview of djot files
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	render := addRenderFlags(flags)
+	var (
+		addr     = flags.String("addr", "localhost:8080", "address to listen on")
+		interval = flags.Duration("interval", 500*time.Millisecond, "polling interval of file changes")
+	)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	options, ok := render.options()
+	if !ok {
+		return 2
+	}
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	server := newPreviewServer(os.DirFS(dir), options)
+	go server.watch(context.Background(), *interval)
+	log.Printf("serving %v on http://%v/", dir, *addr)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Printf("preview server failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
+func newPreviewServer(fsys fs.FS, options renderOptions) *previewServer {
+	s := &previewServer{
+		fsys:        fsys,
+		options:     options,
+		static:      http.FileServer(http.FS(fsys)),
+		subscribers: make(map[chan struct{}]bool),
+	}
+	s.files = s.snapshot()
+	return s
+}
+
+func (s *previewServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == eventsPath {
+		s.events(w, r)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+	if info, err := fs.Stat(s.fsys, name); err == nil && info.IsDir() {
+		if _, err := fs.Stat(s.fsys, path.Join(name, indexFile)); err != nil {
+			s.static.ServeHTTP(w, r)
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		name = path.Join(name, indexFile)
+	} else if base, ok := strings.CutSuffix(name, ".html"); ok && err != nil {
+		// links between documents are usually written to the rendered .html files
+		name = base + lint.Extension
+	}
+	if path.Ext(name) != lint.Extension {
+		s.static.ServeHTTP(w, r)
+		return
+	}
+	document, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(s.render(name, document)))
+}
+
+// render converts the document with its includes like the converter does and adds reload script to the page
+func (s *previewServer) render(name string, document []byte) string {
+	content, diagnostics := s.options.render(s.fsys, name, document)
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Rule == djot_parser.IncludeRule {
+			log.Printf("%v: %v", diagnostic.File, diagnostic.Message)
+		}
+	}
+	writer := &html_writer.HtmlWriter{}
+	writer.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	writer.WriteString("<title>" + html.EscapeString(path.Base(name)) + "</title>\n</head>\n<body>\n")
+	writer.WriteString(string(content))
+	return writer.WriteString(reloadScript + "\n</body>\n</html>\n").String()
+}
+
+func (s *previewServer) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	changes := make(chan struct{}, 1)
+	s.mutex.Lock()
+	s.subscribers[changes] = true
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.subscribers, changes)
+		s.mutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changes:
+			if _, err := fmt.Fprint(w, "data: reload\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// snapshot collects modification times and sizes of all files, unreadable entries are skipped
+func (s *previewServer) snapshot() map[string]fileState {
+	files := make(map[string]fileState)
+	_ = fs.WalkDir(s.fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			files[name] = fileState{modTime: info.ModTime().UnixNano(), size: info.Size()}
+		}
+		return nil
+	})
+	return files
+}
+
+// poll notifies subscribers if any file was created, modified or removed since the previous poll
+func (s *previewServer) poll() bool {
+	files := s.snapshot()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if maps.Equal(files, s.files) {
+		return false
+	}
+	s.files = files
+	for subscriber := range s.subscribers {
+		select {
+		case subscriber <- struct{}{}:
+		default:
+			// subscriber wasn't notified about previous change yet, so page will be reloaded anyway
+		}
+	}
+	return true
+}
+
+func (s *previewServer) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.poll()
+		}
+	}
+}
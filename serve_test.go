@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/internal/testx"
+)
+
+func get(t *testing.T, handler http.Handler, url string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+	return recorder
+}
+
+func TestPreviewServer(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.djot":      {Data: []byte("# Home\n\nSee [docs](docs/intro.html)\n")},
+		"docs/intro.djot": {Data: []byte("_intro_\n")},
+		"docs/style.css":  {Data: []byte("p {}")},
+		"docs/guide.djot": {Data: []byte("# Über\n\n{include=\"../parts/note.djot\"}\n:::\n:::\n")},
+		"parts/note.djot": {Data: []byte("_note_ $`x`\n")},
+		"notes/a.txt":     {Data: []byte("a")},
+	}
+	server := newPreviewServer(fsys, renderOptions{parse: djot_parser.ParseOptions{Slug: djot_parser.ASCIISlug}, format: "html", mathML: true})
+	t.Run("render", func(t *testing.T) {
+		response := get(t, server, "/")
+		testx.AssertEqual(t, "", http.StatusOK, response.Code)
+		testx.AssertEqual(t, "", "text/html; charset=utf-8", response.Header().Get("Content-Type"))
+		body := response.Body.String()
+		testx.AssertTrue(t, "", strings.Contains(body, "<title>index.djot</title>"))
+		testx.AssertTrue(t, "", strings.Contains(body, `<section id="home">`))
+		testx.AssertTrue(t, "", strings.Contains(body, reloadScript))
+		testx.AssertTrue(t, "", strings.Contains(get(t, server, "/docs/intro.html").Body.String(), "<p><em>intro</em></p>"))
+		testx.AssertTrue(t, "", strings.Contains(get(t, server, "/docs/intro.djot").Body.String(), "<p><em>intro</em></p>"))
+		// includes and render options are the same as in the converter
+		guide := get(t, server, "/docs/guide.html").Body.String()
+		testx.AssertTrue(t, "", strings.Contains(guide, `<section id="uber">`))
+		testx.AssertTrue(t, "", strings.Contains(guide, "<p><em>note</em> <span class=\"math inline\"><math"))
+	})
+	t.Run("static", func(t *testing.T) {
+		testx.AssertEqual(t, "", "p {}", get(t, server, "/docs/style.css").Body.String())
+		testx.AssertEqual(t, "", http.StatusNotFound, get(t, server, "/missing.djot").Code)
+		testx.AssertEqual(t, "", http.StatusNotFound, get(t, server, "/missing.html").Code)
+		testx.AssertTrue(t, "", strings.Contains(get(t, server, "/notes/").Body.String(), `href="a.txt"`))
+		testx.AssertEqual(t, "", http.StatusMovedPermanently, get(t, server, "/docs").Code)
+	})
+	t.Run("reload", func(t *testing.T) {
+		httpServer := httptest.NewServer(server)
+		defer httpServer.Close()
+		response, err := http.Get(httpServer.URL + eventsPath)
+		testx.AssertNilError(t, "", err)
+		defer response.Body.Close()
+		testx.AssertEqual(t, "", "text/event-stream", response.Header.Get("Content-Type"))
+		reader := bufio.NewReader(response.Body)
+		line, err := reader.ReadString('\n')
+		testx.AssertNilError(t, "", err)
+		testx.AssertEqual(t, "", ": connected\n", line)
+
+		testx.AssertFalse(t, "", server.poll())
+		fsys["docs/intro.djot"] = &fstest.MapFile{Data: []byte("_changed_\n"), ModTime: time.Now()}
+		testx.AssertTrue(t, "", server.poll())
+		testx.AssertFalse(t, "", server.poll())
+		_, _ = reader.ReadString('\n')
+		line, err = reader.ReadString('\n')
+		testx.AssertNilError(t, "", err)
+		testx.AssertEqual(t, "", "data: reload\n", line)
+	})
+}
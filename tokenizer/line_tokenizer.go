@@ -11,6 +11,9 @@ type LineTokenizer struct {
 
 var newline = []byte("\n")
 
+// Seek moves tokenizer to the offset which must be a start of the line
+func (tokenizer *LineTokenizer) Seek(offset int) { tokenizer.docOffset = offset }
+
 func (tokenizer *LineTokenizer) Scan() (start, end int, eof bool) {
 	if tokenizer.docOffset == len(tokenizer.Document) {
 		eof = true
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"md0.org/djot/djot_parser"
+	"md0.org/djot/lint"
+)
+
+// watcher renders input file into output file every time input changes
+type watcher struct {
+	from, to  string
+	options   renderOptions
+	overwrite bool
+	output    io.Writer // diagnostics of every render are printed here
+}
+
+// runWatch renders input until the process is stopped, only failure of the first render is fatal
+func runWatch(from, to string, options renderOptions, overwrite bool, interval time.Duration) int {
+	if from == "" || from == "-" || to == "" || to == "-" {
+		log.Printf("watch mode requires input and output files")
+		return 1
+	}
+	w := watcher{from: from, to: to, options: options, overwrite: overwrite, output: os.Stderr}
+	if err := w.run(context.Background(), interval); err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
+
+func (w *watcher) run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var previous fileState
+	rendered := false
+	for {
+		info, err := os.Stat(w.from)
+		if err != nil {
+			if !rendered {
+				return fmt.Errorf("failed to open input file %v: %w", w.from, err)
+			}
+			if previous != (fileState{}) {
+				// editors may replace file on save, so it's reported once and rendered when it appears again
+				log.Printf("failed to open input file %v: %v", w.from, err)
+				previous = fileState{}
+			}
+		} else if current := (fileState{modTime: info.ModTime().UnixNano(), size: info.Size()}); current != previous {
+			previous = current
+			if err := w.render(!rendered); err != nil {
+				if !rendered {
+					return err
+				}
+				log.Print(err)
+			} else {
+				rendered = true
+				log.Printf("rendered %v", w.to)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// render converts input with its includes and prints diagnostics; output file can be replaced only by later renders
+// or with -overwrite. Only changes of the input file itself trigger render, included files are read again each time.
+func (w *watcher) render(first bool) error {
+	fsys := os.DirFS(filepath.Dir(w.from))
+	ast, diagnostics, err := djot_parser.BuildDjotAstWithIncludes(fsys, filepath.Base(w.from), 0, w.options.parse)
+	if err != nil {
+		return fmt.Errorf("failed to read input file %v: %w", w.from, err)
+	}
+	documents := make(map[string][]byte)
+	for _, diagnostic := range diagnostics {
+		// diagnostics point into the file where problem was found, it was already read by the include resolver
+		document, ok := documents[diagnostic.File]
+		if !ok {
+			document, _ = fs.ReadFile(fsys, diagnostic.File)
+			documents[diagnostic.File] = document
+		}
+		line, column := djot_parser.DocumentPosition(document, diagnostic.Range.Start)
+		problem := lint.Problem{
+			File:     filepath.Join(filepath.Dir(w.from), filepath.FromSlash(diagnostic.File)),
+			Line:     line,
+			Column:   column,
+			Severity: diagnostic.Severity.String(),
+			Rule:     diagnostic.Rule,
+			Message:  diagnostic.Message,
+		}
+		_, _ = fmt.Fprintln(w.output, problem)
+	}
+	if err := writeFileAtomic(w.to, w.options.renderAst(ast), 0640, w.overwrite || !first); err != nil {
+		return fmt.Errorf("failed to write output file %v: %w", w.to, err)
+	}
+	return nil
+}
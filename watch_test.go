@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"md0.org/djot/internal/testx"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	from, to := filepath.Join(dir, "doc.djot"), filepath.Join(dir, "doc.html")
+	writeFiles(t, dir, map[string]string{"doc.djot": "[a][missing]\n"})
+	var diagnostics bytes.Buffer
+	w := watcher{from: from, to: to, options: renderOptions{format: "html"}, output: &diagnostics}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.run(ctx, time.Millisecond) }()
+	waitFor := func(content string) {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if actual, err := os.ReadFile(to); err == nil && string(actual) == content {
+				return
+			}
+		}
+		t.Fatalf("output wasn't updated to %q", content)
+	}
+	waitFor("<p><a>a</a></p>\n")
+	future := time.Now().Add(time.Hour)
+	writeFiles(t, dir, map[string]string{"doc.djot": "_b_\n"})
+	testx.AssertNilError(t, "", os.Chtimes(from, future, future))
+	waitFor("<p><em>b</em></p>\n")
+	cancel()
+	testx.AssertNilError(t, "", <-done)
+	testx.AssertEqual(t, "", from+":1:1: undefined-reference: undefined reference 'missing'\n", diagnostics.String())
+
+	t.Run("existing output", func(t *testing.T) {
+		err := (&watcher{from: from, to: to, options: renderOptions{format: "html"}, output: &diagnostics}).run(context.Background(), time.Millisecond)
+		testx.AssertNotNil(t, "", err)
+		testx.AssertEqual(t, "", "<p><em>b</em></p>\n", readFile(t, to))
+	})
+	t.Run("includes", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"book/main.djot":      "{include=\"parts/one.djot\"}\n:::\n:::\n",
+			"book/parts/one.djot": "_one_ [x][nope]\n",
+		})
+		var diagnostics bytes.Buffer
+		output := filepath.Join(dir, "main.html")
+		w := watcher{from: filepath.Join(dir, "book", "main.djot"), to: output, options: renderOptions{format: "html"}, output: &diagnostics}
+		testx.AssertNilError(t, "", w.render(true))
+		testx.AssertEqual(t, "", "<p><em>one</em> <a>x</a></p>\n", readFile(t, output))
+		testx.AssertEqual(t, "", filepath.Join(dir, "book", "parts", "one.djot")+":1:7: undefined-reference: undefined reference 'nope'\n", diagnostics.String())
+	})
+	t.Run("missing input", func(t *testing.T) {
+		err := (&watcher{from: filepath.Join(dir, "missing.djot"), to: to}).run(context.Background(), time.Millisecond)
+		testx.AssertNotNil(t, "", err)
+	})
+	t.Run("temporary files are removed", func(t *testing.T) {
+		entries, err := os.ReadDir(dir)
+		testx.AssertNilError(t, "", err)
+		names := make([]string, 0)
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		testx.AssertEqual(t, "", []string{"doc.djot", "doc.html"}, names)
+	})
+}